@@ -5,6 +5,7 @@ import (
 	"grader/common"
 	"log"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -21,6 +22,13 @@ var topic string
 var encryptedServiceAccountJSON []byte
 
 func main() {
+	key := []byte(localhost + localhost)
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		common.RunSubmitCommand(os.Args[2:], encryptedServiceAccountJSON, key, project, topic)
+		return
+	}
+
+	common.ParseFlags()
 	currentTime := time.Now()
 	log.Println("Current Timestamp: " + currentTime.Format(time.RFC3339))
 
@@ -30,7 +38,7 @@ func main() {
 	log.Printf("Result: %t\n", finalResult)
 
 	if finalResult {
-		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, []byte(localhost+localhost), project, topic)
+		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, key, project, topic, "")
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"grader/common"
 	"log"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -21,6 +22,13 @@ var topic string
 var encryptedServiceAccountJSON []byte
 
 func main() {
+	key := []byte(grader + localhost + project)
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		common.RunSubmitCommand(os.Args[2:], encryptedServiceAccountJSON, key, project, topic)
+		return
+	}
+
+	common.ParseFlags()
 	currentTime := time.Now()
 	log.Println("Current Timestamp: " + currentTime.Format(time.RFC3339))
 
@@ -30,7 +38,7 @@ func main() {
 	log.Printf("Result: %t\n", finalResult)
 
 	if finalResult {
-		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, []byte(grader+localhost+project), project, topic)
+		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, key, project, topic, "")
 	}
 }
 
@@ -40,7 +48,7 @@ func checkAllServices() []bool {
 
 	result := []bool{
 		common.CheckResult(common.CheckRunningContainers(containerNames), "All specified containers are running."),
-		common.CheckResult(common.CheckDockerComposeRunning(), "Docker compose is running."),
+		common.CheckResult(common.CheckComposeProject(common.ComposeExpectations["activity2"]), "Docker compose project is running."),
 		common.CheckResult(common.CheckNetwork(networkName), "Network exists."),
 		common.CheckResult(common.CheckHTTPStatus(localhost+":3000", http.StatusOK, "Grafana was not found via http://localhost:3000. Please check your Grafana service."), "Grafana is up and running at http://localhost:3000"),
 		common.CheckResult(common.CheckHTTPStatus(localhost+":9090", http.StatusOK, "Prometheus was not found via http://localhost:9090. Please check your Prometheus service."), "Prometheus is up and running at http://localhost:9090"),
@@ -2,9 +2,11 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"grader/common"
 	"log"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -12,7 +14,6 @@ const (
 	networkName     = "todo-net"
 	localhost       = "http://localhost"
 	pageURL         = "http://localhost:3000"
-	scriptURL       = "http://localhost:3000/static/js/bundle.js"
 	localhost8000   = "http://localhost:8000"
 	localhost9000   = "http://localhost:9000"
 	todoServiceURL  = "http://localhost:8000/todo"
@@ -26,7 +27,20 @@ var topic string
 //go:embed activity3.json.enc
 var encryptedServiceAccountJSON []byte
 
+var (
+	allowDisruptive      = flag.Bool("allow-disruptive", false, "run checks that restart services without asking")
+	checkSecurityHeaders = flag.Bool("check-security-headers", false, "check that the api-gateway sends common security headers")
+	confirmBackends      = flag.Bool("confirm-gateway-backends", false, "confirm from container logs that each gateway route reaches the right service")
+)
+
 func main() {
+	key := []byte(notificationURL[0:32])
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		common.RunSubmitCommand(os.Args[2:], encryptedServiceAccountJSON, key, project, topic)
+		return
+	}
+
+	common.ParseFlags()
 	currentTime := time.Now()
 	log.Println("Current Timestamp: " + currentTime.Format(time.RFC3339))
 
@@ -36,23 +50,47 @@ func main() {
 	log.Printf("Result: %t\n", finalResult)
 
 	if finalResult {
-		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, []byte(notificationURL[0:32]), project, topic)
+		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, key, project, topic, "")
 	}
 }
 
 func checkAllServices() []bool {
 
 	containerNames := []string{"webapp", "todo-service", "notification-service", "redis", "api-gateway"}
+	webappAssertions := []common.HTMLAssertion{
+		{Selector: "title", Equals: "Uber To Do"},
+		{Selector: "script[src]", Attr: "src", Matches: `^/static/js/(bundle|main\.[0-9a-f]+)\.js$`},
+		{Selector: "meta[name=viewport]"},
+	}
+	routes := []common.GatewayRoute{
+		{Path: "/todo", Container: "todo-service"},
+		{Path: "/notification", Container: "notification-service"},
+	}
 
 	result := []bool{
 		common.CheckResult(common.CheckNetwork(networkName), "Network exists."),
 		common.CheckResult(common.CheckRunningContainers(containerNames), "All specified containers are running."),
-		common.CheckResult(common.CheckDockerComposeRunning(), "Docker compose is running."),
-		common.CheckResult(common.CheckTodoWebapp(pageURL, scriptURL), "Todo app is working."),
-		common.CheckResult(common.CheckHTTPStatus(localhost8000, http.StatusNotFound, "Please make sure that you set up services behind api-gateway."), ""),
-		common.CheckResult(common.CheckHTTPStatus(localhost9000, http.StatusNotFound, "Please make sure that you expose ports only webapp and api-gateway."), ""),
-		common.CheckResult(common.CheckHTTPStatus(todoServiceURL, http.StatusOK, "Todo-service was not found. Please check your api-gateway"), ""),
+		common.CheckResult(common.CheckComposeProject(common.ComposeExpectations["activity3"]), "Docker compose project is running."),
+		common.CheckResult(common.CheckTodoWebapp(pageURL, webappAssertions), "Todo app is working."),
+		common.CheckResult(common.CheckHTTPStatus(localhost8000, http.StatusNotFound, "Please make sure that you set up services behind api-gateway."), "Api-gateway does not serve unknown paths at http://localhost:8000."),
+		common.CheckResult(common.CheckHTTPStatus(localhost9000, http.StatusNotFound, "Please make sure that you expose ports only webapp and api-gateway."), "Port 9000 is not exposed."),
+		common.CheckResult(common.CheckRedisUnreachable("localhost:6379"), "Redis is not exposed at localhost:6379."),
+		common.CheckResult(common.CheckHTTPStatus(todoServiceURL, http.StatusOK, "Todo-service was not found. Please check your api-gateway"), "Todo-service found with api-gateway."),
 		common.CheckResult(common.CheckHTTPStatus(notificationURL, http.StatusOK, "Notification-service was not found. Please check your api-gateway"), "Notification-service found with api-gateway."),
+		common.CheckResult(common.CheckGatewayRouting(localhost8000, routes, *confirmBackends), "Api-gateway routes each path to the right service."),
+		common.CheckResult(common.CheckWebappIntegration(pageURL, localhost8000, "/todo"), "Webapp is integrated with the api-gateway."),
+	}
+
+	if *checkSecurityHeaders {
+		result = append(result, common.CheckResult(common.CheckSecurityHeaders(todoServiceURL, common.DefaultSecurityHeaders), "Api-gateway sends security headers."))
+	}
+
+	if common.AllowDisruptive(*allowDisruptive) {
+		restartRedis := func() error { return common.RestartContainer("redis") }
+		result = append(result,
+			common.CheckResult(common.CheckTodoPersistence(todoServiceURL, restartRedis), "Todo data survives a redis restart."),
+			common.CheckResult(common.CheckContainerRecovery("todo-service", todoServiceURL, 30*time.Second), "Todo-service recovers after being killed."),
+		)
 	}
 	return result
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"grader/common"
 	"log"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -15,39 +17,121 @@ const (
 	grader           = "grader"
 )
 
+// Latency under load is only graded with -check-benchmark, since it depends
+// on the student's machine.
+var benchmarkThresholds = common.BenchmarkThresholds{Requests: 200, Concurrency: 10, MaxP95: time.Second, MaxErrorRate: 0.01}
+
+// Autoscaling of the todo deployment is only graded with -check-autoscaling.
+const (
+	todoDeployment     = "todo"
+	minReplicas        = 1
+	maxReplicas        = 5
+	cpuTarget          = 50
+	loadConcurrency    = 50
+	autoscalingTimeout = 3 * time.Minute
+)
+
 // topic is the Pub/Sub topic name, which will be set at build time.
 var topic string
 
 //go:embed activity4.json.enc
 var encryptedServiceAccountJSON []byte
 
+var (
+	allowCluster         = flag.String("allow-cluster", "", "run disruptive checks against this kubectl context without the production safety checks")
+	allowDisruptive      = flag.Bool("allow-disruptive", false, "run checks that restart services without asking")
+	checkAutoscaling     = flag.Bool("check-autoscaling", false, "check the HorizontalPodAutoscaler of the todo deployment and scale it up with load")
+	checkBenchmark       = flag.Bool("check-benchmark", false, "check that the todo service keeps up with load behind the ingress")
+	checkSecurityHeaders = flag.Bool("check-security-headers", false, "check that the ingress sends common security headers")
+	corsOrigin           = flag.String("cors-origin", "", "check that the ingress allows CORS preflight requests from this origin, e.g. http://localhost:3000")
+)
+
 func main() {
+	key := []byte(localhost + localhost)
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		common.RunSubmitCommand(os.Args[2:], encryptedServiceAccountJSON, key, project, topic)
+		return
+	}
+
+	common.ParseFlags()
 	currentTime := time.Now()
 	log.Println("Current Timestamp: " + currentTime.Format(time.RFC3339))
 
-	result := checkAllServices()
+	cluster, clusterErr := common.DetectKubernetesCluster()
+	if clusterErr != nil {
+		log.Printf("%sFailed to detect Kubernetes cluster: %v\n", common.ErrorPrefix, clusterErr)
+	} else {
+		log.Printf("Kubernetes cluster: %s at %s\n", cluster, cluster.Server)
+	}
+
+	result := checkAllServices(cluster, clusterErr)
 
 	finalResult := common.AllTrue(result)
 	log.Printf("Result: %t\n", finalResult)
 
 	if finalResult {
-		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, []byte(localhost+localhost), project, topic)
+		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, key, project, topic, cluster.String())
 	}
 }
 
-func checkAllServices() []bool {
+func checkAllServices(cluster common.ClusterInfo, clusterErr error) []bool {
 	namespace := common.CollectInfo("Kubernetes namespace", defaultNamespace)
 	domain := common.EnsureHTTPPrefix(common.CollectInfo("domain", localhost))
 
 	result := []bool{
 		common.CheckResult(common.CheckNamespaceExists(namespace), "Namespace exists and can use kubectl command."),
-		common.CheckResult(common.CheckKubernetesResources(namespace), "All Kubernetes resources are up and running."),
+		common.CheckResult(common.CheckKubernetesResources(namespace, common.TodoKubernetesExpectation), "All Kubernetes resources are up and running."),
 		common.CheckResult(common.CheckIngressExists(namespace), "Ingress resource exists in the namespace."),
+		common.CheckResult(common.CheckInClusterDNS(namespace, "redis"), "Redis service can be resolved inside the cluster."),
+		common.CheckResult(common.CheckInClusterTCP(namespace, "redis", 6379), "Redis is reachable from inside the cluster at redis:6379."),
+		common.CheckResult(common.CheckInClusterHTTPStatus(namespace, "http://todo", http.StatusOK), "Todo service is reachable from inside the cluster at http://todo."),
 		common.CheckResult(common.CheckHTTPStatus(domain, http.StatusOK, "Todo-service was not found via http://localhost. Please check your nginx-ingress service."), "Todo is up and running at http://localhost"),
 		common.CheckResult(common.CheckHTTPStatus(domain+":8000", http.StatusNotFound, "Todo service at http://localhost:8000 should be inaccessible. Please check your nginx-ingress service."), "Todo service is inaccessible at http://localhost:8000"),
 		common.CheckResult(common.CheckHTTPStatus(domain+":6379", http.StatusNotFound, "Redis service at http://localhost:6379 should be inaccessible. Please check your nginx-ingress service."), "Redis service is inaccessible at http://localhost:6379"),
 		common.CheckResult(common.SendPostRequest(domain, true), "POST request to http://localhost was successful."),
 		common.CheckResult(common.SendGetRequest(domain, grader), "GET request shows result from previous POST request to http://localhost."),
+		common.CheckResult(common.CheckRedisInCluster(namespace, "redis", "", false, grader), "Redis holds the todo from the previous POST request."),
+	}
+
+	if *checkBenchmark {
+		result = append(result, common.CheckResult(common.CheckBenchmark(domain, benchmarkThresholds), "Todo service keeps up with load behind the ingress."))
+	}
+
+	if *checkSecurityHeaders {
+		result = append(result, common.CheckResult(common.CheckSecurityHeaders(domain, common.DefaultSecurityHeaders), "Ingress sends security headers."))
+	}
+
+	if *corsOrigin != "" {
+		result = append(result, common.CheckResult(common.CheckCORS(domain, *corsOrigin, http.MethodPost, []string{"content-type"}), "Ingress allows CORS requests from "+*corsOrigin+"."))
+	}
+
+	if *checkAutoscaling {
+		result = append(result,
+			common.CheckResult(common.CheckDeploymentResourceLimits(namespace, todoDeployment), "Todo deployment sets CPU requests and resource limits."),
+			common.CheckResult(common.CheckHorizontalPodAutoscaler(namespace, todoDeployment, minReplicas, maxReplicas, cpuTarget), "HorizontalPodAutoscaler scales the todo deployment."),
+			common.CheckResult(common.CheckAutoscaling(namespace, todoDeployment, domain, loadConcurrency, autoscalingTimeout), "Todo deployment scales up under load."),
+		)
+	}
+
+	if common.AllowDisruptive(*allowDisruptive) && clusterSafe(cluster, clusterErr) {
+		restartRedis := func() error { return common.RestartPods(namespace, "redis") }
+		result = append(result,
+			common.CheckResult(common.CheckTodoPersistence(domain, restartRedis), "Todo data survives a redis restart."),
+			common.CheckResult(common.CheckPodRecovery(namespace, "todo", domain, time.Minute), "Todo service recovers after its pod is deleted."),
+		)
 	}
 	return result
 }
+
+// clusterSafe guards the disruptive checks. Skipping them does not fail the
+// run, since they are optional.
+func clusterSafe(cluster common.ClusterInfo, clusterErr error) bool {
+	if clusterErr == nil {
+		clusterErr = common.CheckClusterSafety(cluster, *allowCluster)
+	}
+	if clusterErr != nil {
+		log.Printf(common.SpacePrefix+"Skipping disruptive checks: %v\n", clusterErr)
+		return false
+	}
+	return true
+}
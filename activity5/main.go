@@ -2,17 +2,20 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"grader/common"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"time"
 )
 
 const (
-	localhost = "http://localhost"
-	project   = "sds-grader"
-	grader    = "grader"
+	localhost   = "http://localhost"
+	networkName = "todo-net"
+	project     = "sds-grader"
+	grader      = "grader"
 )
 
 // topic is the Pub/Sub topic name, which will be set at build time.
@@ -21,7 +24,20 @@ var topic string
 //go:embed activity5.json.enc
 var encryptedServiceAccountJSON []byte
 
+var (
+	keepWorkspace   = flag.Bool("keep-workspace", false, "keep the temporary terraform workspace for debugging")
+	providerMirror  = flag.String("provider-mirror", os.Getenv("SDS_GRADER_PROVIDER_MIRROR"), "install terraform providers from this local filesystem mirror")
+	allowDisruptive = flag.Bool("allow-disruptive", false, "run checks that restart services without asking")
+)
+
 func main() {
+	key := []byte(localhost + project + grader)
+	if len(os.Args) > 1 && os.Args[1] == "submit" {
+		common.RunSubmitCommand(os.Args[2:], encryptedServiceAccountJSON, key, project, topic)
+		return
+	}
+
+	common.ParseFlags()
 	currentTime := time.Now()
 	log.Println("Current Timestamp: " + currentTime.Format(time.RFC3339))
 
@@ -31,24 +47,53 @@ func main() {
 	log.Printf("Result: %t\n", finalResult)
 
 	if finalResult {
-		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, []byte(localhost+project+grader), project, topic)
+		common.HandleSuccess(currentTime, encryptedServiceAccountJSON, key, project, topic, "")
 	}
 }
 
 func checkAllServices() []bool {
 	containerNames := []string{"todo-service", "redis"}
+	containers := []common.ContainerExpectation{
+		{Name: "todo-service", Image: "todo", Ports: []int{8000}, Networks: []string{networkName}},
+		{Name: "redis", Image: "redis", Networks: []string{networkName}},
+	}
+	config := common.TerraformConfigExpectation{Provider: "docker", ProviderSource: "kreuzwerker/docker"}
 	domain := localhost
 	tfFilePath := common.CollectInfo("[REQUIRED] Terraform file path (.tf)", "main.tf")
 
+	if !common.CheckResult(common.CheckFilePath(tfFilePath, ".tf"), "Terraform file path is exist.") {
+		return []bool{false}
+	}
+	workspace, err := common.NewTerraformSandbox(filepath.Dir(tfFilePath), *keepWorkspace)
+	if !common.CheckResult(err, "Terraform module is copied to a temporary workspace.") {
+		return []bool{false}
+	}
+	defer workspace.Cleanup()
+	if *providerMirror != "" {
+		if !common.CheckResult(workspace.UseProviderMirror(*providerMirror), "Terraform uses the provider mirror at "+*providerMirror+".") ||
+			!common.CheckResult(common.CheckProviderMirror(*providerMirror, config.ProviderSource), "Provider mirror contains "+config.ProviderSource+".") {
+			return []bool{false}
+		}
+	}
+
 	result := []bool{
-		common.CheckResult(common.CheckFilePath(tfFilePath, ".tf"), "Terraform file path is exist."),
 		common.CheckResult(common.CheckCmdExitCode("terraform", "version"), "Terraform is installed."),
-		common.CheckResult(common.CheckCmdExitCode("terraform", "-chdir="+filepath.Dir(tfFilePath), "init"), "Terraform is initialized."),
-		common.CheckResult(common.CheckCmdExitCode("terraform", "-chdir="+filepath.Dir(tfFilePath), "plan"), "Terraform plan is generated."),
+		common.CheckResult(common.CheckTerraformFormat(workspace), "Terraform files are formatted."),
+		common.CheckResult(workspace.Init(), "Terraform is initialized."),
+		common.CheckResult(common.CheckTerraformValidate(workspace), "Terraform configuration is valid."),
+		common.CheckResult(common.CheckTerraformConfiguration(workspace, config), "Terraform provider is declared and pinned."),
+		common.CheckResult(common.CheckTerraformPlan(workspace, containers), "Terraform plan contains the expected containers."),
 		common.CheckResult(common.CheckRunningContainers(containerNames), "All specified containers are running."),
+		common.CheckResult(common.CheckTerraformStateMatchesContainers(workspace, containerNames), "Running containers match terraform state."),
+		common.CheckResult(common.CheckTerraformDrift(workspace), "No drift between terraform configuration and running containers."),
 		common.CheckResult(common.CheckHTTPStatus(domain+":8000", http.StatusOK, "Todo-service was not found via http://localhost:8000. Please check your nginx-ingress service."), "Todo is up and running at http://localhost:8000"),
 		common.CheckResult(common.SendPostRequest(domain+":8000", true), "POST request to http://localhost:8000 was successful."),
 		common.CheckResult(common.SendGetRequest(domain+":8000", grader), "GET request shows result from previous POST request to http://localhost:8000."),
 	}
+
+	if common.AllowDisruptive(*allowDisruptive) {
+		restartRedis := func() error { return common.RestartContainer("redis") }
+		result = append(result, common.CheckResult(common.CheckTodoPersistence(domain+":8000", restartRedis), "Todo data survives a redis restart."))
+	}
 	return result
 }
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type hpaList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			ScaleTargetRef struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"scaleTargetRef"`
+			MinReplicas                    *int `json:"minReplicas"`
+			MaxReplicas                    int  `json:"maxReplicas"`
+			TargetCPUUtilizationPercentage *int `json:"targetCPUUtilizationPercentage"`
+			Metrics                        []struct {
+				Type     string `json:"type"`
+				Resource *struct {
+					Name   string `json:"name"`
+					Target struct {
+						AverageUtilization *int `json:"averageUtilization"`
+					} `json:"target"`
+				} `json:"resource"`
+			} `json:"metrics"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+func CheckHorizontalPodAutoscaler(namespace, deployment string, minReplicas, maxReplicas, cpuTarget int) error {
+	cmd := exec.Command("kubectl", "get", "hpa", "-n", namespace, "-o", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to execute kubectl command: %v", err)
+	}
+	return checkHorizontalPodAutoscalers(output, namespace, deployment, minReplicas, maxReplicas, cpuTarget)
+}
+
+// checkHorizontalPodAutoscalers checks the autoscaler of deployment in a
+// 'kubectl get hpa -o json' list. Both autoscaling/v1 and v2 CPU targets are
+// understood.
+func checkHorizontalPodAutoscalers(output []byte, namespace, deployment string, minReplicas, maxReplicas, cpuTarget int) error {
+	var list hpaList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return fmt.Errorf("failed to parse HorizontalPodAutoscaler list: %v", err)
+	}
+
+	for _, hpa := range list.Items {
+		spec := hpa.Spec
+		if spec.ScaleTargetRef.Kind != "Deployment" || spec.ScaleTargetRef.Name != deployment {
+			continue
+		}
+
+		minimum := 1
+		if spec.MinReplicas != nil {
+			minimum = *spec.MinReplicas
+		}
+		if minimum != minReplicas || spec.MaxReplicas != maxReplicas {
+			return fmt.Errorf("HorizontalPodAutoscaler %s scales between %d and %d replicas, expected %d and %d", hpa.Metadata.Name, minimum, spec.MaxReplicas, minReplicas, maxReplicas)
+		}
+
+		cpu := -1
+		if spec.TargetCPUUtilizationPercentage != nil {
+			cpu = *spec.TargetCPUUtilizationPercentage
+		}
+		for _, metric := range spec.Metrics {
+			if metric.Type == "Resource" && metric.Resource != nil && metric.Resource.Name == "cpu" && metric.Resource.Target.AverageUtilization != nil {
+				cpu = *metric.Resource.Target.AverageUtilization
+			}
+		}
+		if cpu == -1 {
+			return fmt.Errorf("HorizontalPodAutoscaler %s has no CPU utilization target, expected %d%%", hpa.Metadata.Name, cpuTarget)
+		}
+		if cpu != cpuTarget {
+			return fmt.Errorf("HorizontalPodAutoscaler %s targets %d%% CPU, expected %d%%", hpa.Metadata.Name, cpu, cpuTarget)
+		}
+
+		log.Printf(SpacePrefix+SuccessPrefix+"HorizontalPodAutoscaler %s targets deployment %s.\n", hpa.Metadata.Name, deployment)
+		return nil
+	}
+	return fmt.Errorf("no HorizontalPodAutoscaler targets deployment %s in namespace %s", deployment, namespace)
+}
+
+func CheckDeploymentResourceLimits(namespace, deployment string) error {
+	cmd := exec.Command("kubectl", "get", "deployment", deployment, "-n", namespace, "-o", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to execute kubectl command: %v", err)
+	}
+
+	var d struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Name      string `json:"name"`
+						Resources struct {
+							Requests map[string]string `json:"requests"`
+							Limits   map[string]string `json:"limits"`
+						} `json:"resources"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(output, &d); err != nil {
+		return fmt.Errorf("failed to parse deployment %s: %v", deployment, err)
+	}
+
+	for _, c := range d.Spec.Template.Spec.Containers {
+		if c.Resources.Requests["cpu"] == "" {
+			return fmt.Errorf("container %s of deployment %s has no CPU request", c.Name, deployment)
+		}
+		if c.Resources.Limits["cpu"] == "" || c.Resources.Limits["memory"] == "" {
+			return fmt.Errorf("container %s of deployment %s has no CPU or memory limit", c.Name, deployment)
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"Container %s requests %s CPU with limits %s CPU / %s memory.\n", c.Name, c.Resources.Requests["cpu"], c.Resources.Limits["cpu"], c.Resources.Limits["memory"])
+	}
+	return nil
+}
+
+func GetDeploymentReplicas(namespace, deployment string) (int, error) {
+	cmd := exec.Command("kubectl", "get", "deployment", deployment, "-n", namespace, "-o", "jsonpath={.status.replicas}")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute kubectl command: %v", err)
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// GenerateLoad sends GET requests to url from concurrency workers until ctx
+// is done and returns the number of requests sent.
+func GenerateLoad(ctx context.Context, url string, concurrency int) int64 {
+	var sent int64
+	var wg sync.WaitGroup
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+				if err != nil {
+					return
+				}
+				resp, err := client.Do(req)
+				atomic.AddInt64(&sent, 1)
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return atomic.LoadInt64(&sent)
+}
+
+func CheckAutoscaling(namespace, deployment, url string, concurrency int, window time.Duration) error {
+	initial, err := GetDeploymentReplicas(namespace, deployment)
+	if err != nil {
+		return fmt.Errorf("failed to get replicas of deployment %s: %v", deployment, err)
+	}
+	log.Printf(SpacePrefix+"Generating load on %s for up to %s (deployment %s has %d replicas)...\n", url, window, deployment, initial)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+	done := make(chan int64)
+	go func() { done <- GenerateLoad(ctx, url, concurrency) }()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			sent := <-done
+			return fmt.Errorf("deployment %s did not scale up within %s after %d requests", deployment, window, sent)
+		case <-ticker.C:
+			replicas, err := GetDeploymentReplicas(namespace, deployment)
+			if err != nil || replicas <= initial {
+				continue
+			}
+			cancel()
+			sent := <-done
+			log.Printf(SpacePrefix+SuccessPrefix+"Deployment %s scaled from %d to %d replicas in %s after %d requests.\n", deployment, initial, replicas, time.Since(start).Round(time.Second), sent)
+			return nil
+		}
+	}
+}
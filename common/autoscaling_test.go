@@ -0,0 +1,63 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckHorizontalPodAutoscalers(t *testing.T) {
+	tests := []struct {
+		name    string
+		hpa     string
+		wantErr string
+	}{
+		{
+			name: "autoscaling/v2 cpu metric",
+			hpa:  `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"todo"},"minReplicas":1,"maxReplicas":5,"metrics":[{"type":"Resource","resource":{"name":"cpu","target":{"type":"Utilization","averageUtilization":50}}}]}}`,
+		},
+		{
+			name: "autoscaling/v1 target and default min replicas",
+			hpa:  `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"todo"},"maxReplicas":5,"targetCPUUtilizationPercentage":50}}`,
+		},
+		{
+			name:    "wrong max replicas",
+			hpa:     `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"todo"},"minReplicas":1,"maxReplicas":10,"targetCPUUtilizationPercentage":50}}`,
+			wantErr: "scales between 1 and 10 replicas, expected 1 and 5",
+		},
+		{
+			name:    "wrong cpu target",
+			hpa:     `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"todo"},"maxReplicas":5,"metrics":[{"type":"Resource","resource":{"name":"cpu","target":{"averageUtilization":80}}}]}}`,
+			wantErr: "targets 80% CPU, expected 50%",
+		},
+		{
+			name:    "memory metric only",
+			hpa:     `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"todo"},"maxReplicas":5,"metrics":[{"type":"Resource","resource":{"name":"memory","target":{"averageUtilization":50}}}]}}`,
+			wantErr: "has no CPU utilization target, expected 50%",
+		},
+		{
+			name:    "other deployment",
+			hpa:     `{"metadata":{"name":"redis"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"redis"},"maxReplicas":5,"targetCPUUtilizationPercentage":50}}`,
+			wantErr: "no HorizontalPodAutoscaler targets deployment todo",
+		},
+		{
+			name:    "stateful set with the same name",
+			hpa:     `{"metadata":{"name":"todo"},"spec":{"scaleTargetRef":{"kind":"StatefulSet","name":"todo"},"maxReplicas":5,"targetCPUUtilizationPercentage":50}}`,
+			wantErr: "no HorizontalPodAutoscaler targets deployment todo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := []byte(`{"items":[` + tt.hpa + `]}`)
+			err := checkHorizontalPodAutoscalers(output, "default", "todo", 1, 5, 50)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkHorizontalPodAutoscalers() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkHorizontalPodAutoscalers() = %v, want error %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type BenchmarkResult struct {
+	Requests  int
+	Errors    int
+	Duration  time.Duration
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	ErrorRate float64
+}
+
+func (r BenchmarkResult) String() string {
+	return fmt.Sprintf("%d requests in %s (%.1f req/s), p50=%s p95=%s p99=%s, error rate %.2f%%",
+		r.Requests, r.Duration.Round(time.Millisecond), float64(r.Requests)/r.Duration.Seconds(),
+		r.P50.Round(time.Millisecond), r.P95.Round(time.Millisecond), r.P99.Round(time.Millisecond), r.ErrorRate*100)
+}
+
+type BenchmarkThresholds struct {
+	Requests     int
+	Concurrency  int
+	MaxP95       time.Duration
+	MaxErrorRate float64
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// RunBenchmark sends requests GET requests to url from concurrency workers.
+// Failed requests and responses with status 400 or above count as errors.
+func RunBenchmark(url string, requests, concurrency int) BenchmarkResult {
+	client := &http.Client{Timeout: 10 * time.Second}
+	jobs := make(chan struct{}, requests)
+	for i := 0; i < requests; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	latencies := make([]time.Duration, 0, requests)
+	errors := 0
+
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				sent := time.Now()
+				resp, err := client.Get(url)
+				failed := err != nil
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					failed = resp.StatusCode >= 400
+				}
+				latency := time.Since(sent)
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				if failed {
+					errors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result := BenchmarkResult{
+		Requests: requests,
+		Errors:   errors,
+		Duration: time.Since(start),
+		P50:      percentile(latencies, 0.50),
+		P95:      percentile(latencies, 0.95),
+		P99:      percentile(latencies, 0.99),
+	}
+	if requests > 0 {
+		result.ErrorRate = float64(errors) / float64(requests)
+	}
+	return result
+}
+
+func CheckBenchmark(url string, thresholds BenchmarkThresholds) error {
+	log.Printf(SpacePrefix+"Sending %d requests to %s with concurrency %d...\n", thresholds.Requests, url, thresholds.Concurrency)
+	result := RunBenchmark(url, thresholds.Requests, thresholds.Concurrency)
+	log.Printf(SpacePrefix+"%s\n", result)
+
+	if result.ErrorRate > thresholds.MaxErrorRate {
+		return fmt.Errorf("error rate %.2f%% is above %.2f%% (%d of %d requests failed)", result.ErrorRate*100, thresholds.MaxErrorRate*100, result.Errors, result.Requests)
+	}
+	if thresholds.MaxP95 > 0 && result.P95 > thresholds.MaxP95 {
+		return fmt.Errorf("p95 latency %s is above %s", result.P95.Round(time.Millisecond), thresholds.MaxP95)
+	}
+	return nil
+}
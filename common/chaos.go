@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CrashContainer kills the main process of a container from a helper
+// container in the host PID namespace. 'docker kill' is not used because the
+// daemon records it as a manual stop and then ignores the restart policy,
+// while a crash is what the restart policy is meant to handle.
+//
+// The PID and ID come from a single 'docker inspect' done by the caller. If
+// the container restarted since then, the PID may belong to another process,
+// so the helper only sends the signal while that process is still in the
+// cgroup of the inspected container.
+func CrashContainer(info ContainerInfo) error {
+	name := strings.TrimPrefix(info.Name, "/")
+	if !info.State.Running || info.State.Pid == 0 {
+		return fmt.Errorf("container %s is not running", name)
+	}
+
+	pid := strconv.Itoa(info.State.Pid)
+	script := fmt.Sprintf(`grep -q %s /proc/%s/cgroup || { echo "process %s no longer belongs to the container"; exit 3; }; kill -9 %s`, info.ID, pid, pid, pid)
+	cmd := exec.Command("docker", "run", "--rm", "--pid=host", "--cgroupns=host", probeBusyboxImage, "sh", "-c", script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to kill container %s: %v %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func CheckContainerRecovery(containerName, url string, deadline time.Duration) error {
+	before, err := InspectContainer(containerName)
+	if err != nil {
+		return err
+	}
+
+	log.Printf(SpacePrefix+"Killing container %s...\n", containerName)
+	if err := CrashContainer(before); err != nil {
+		return err
+	}
+	start := time.Now()
+
+	for time.Since(start) < deadline {
+		info, err := InspectContainer(containerName)
+		if err == nil && info.State.Running && info.State.StartedAt != before.State.StartedAt && httpReady(url) {
+			log.Printf(SpacePrefix+SuccessPrefix+"Container %s recovered in %s.\n", containerName, time.Since(start).Round(100*time.Millisecond))
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if policy := before.HostConfig.RestartPolicy.Name; policy == "" || policy == "no" {
+		return fmt.Errorf("container %s did not recover within %s. It has no restart policy", containerName, deadline)
+	}
+	return fmt.Errorf("container %s did not recover within %s", containerName, deadline)
+}
+
+func CheckPodRecovery(namespace, service, url string, deadline time.Duration) error {
+	pods, selector, err := GetServicePods(namespace, service)
+	if err != nil {
+		return err
+	}
+	name := pods[0].Name
+
+	log.Printf(SpacePrefix+"Deleting pod %s...\n", name)
+	if output, err := exec.Command("kubectl", "delete", "pod", name, "-n", namespace, "--wait=false").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete pod %s: %v %s", name, err, strings.TrimSpace(string(output)))
+	}
+	start := time.Now()
+
+	if err := WaitForPodsReady(namespace, selector, pods, deadline); err != nil {
+		return fmt.Errorf("pod %s was not replaced within %s", name, deadline)
+	}
+	for !httpReady(url) {
+		if time.Since(start) > deadline {
+			return fmt.Errorf("URL %s did not recover within %s", url, deadline)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	log.Printf(SpacePrefix+SuccessPrefix+"Pod %s was replaced and service recovered in %s.\n", name, time.Since(start).Round(100*time.Millisecond))
+	return nil
+}
+
+func httpReady(url string) bool {
+	if url == "" {
+		return true
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
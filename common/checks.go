@@ -10,10 +10,10 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-
-	"golang.org/x/net/html"
 )
 
 const (
@@ -43,6 +43,69 @@ func GetNetworkName(containerName string) (string, error) {
 	return string(output), nil
 }
 
+type ContainerInfo struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Image   string `json:"Image"`
+	Created string `json:"Created"`
+	State   struct {
+		Status    string `json:"Status"`
+		Running   bool   `json:"Running"`
+		Pid       int    `json:"Pid"`
+		StartedAt string `json:"StartedAt"`
+		ExitCode  int    `json:"ExitCode"`
+		Health    *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	RestartCount int `json:"RestartCount"`
+	Config       struct {
+		Hostname string            `json:"Hostname"`
+		Image    string            `json:"Image"`
+		Labels   map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+		RestartPolicy struct {
+			Name string `json:"Name"`
+		} `json:"RestartPolicy"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]json.RawMessage `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (c ContainerInfo) HostPorts() []int {
+	var ports []int
+	for _, bindings := range c.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			if port, err := strconv.Atoi(binding.HostPort); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+func InspectContainer(containerName string) (ContainerInfo, error) {
+	cmd := exec.Command("docker", "inspect", "--type", "container", containerName)
+	output, err := cmd.Output()
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("failed to inspect container %s: %v", containerName, err)
+	}
+
+	var containers []ContainerInfo
+	if err := json.Unmarshal(output, &containers); err != nil || len(containers) == 0 {
+		return ContainerInfo{}, fmt.Errorf("failed to parse docker inspect output for %s: %v", containerName, err)
+	}
+	RecordEvidence("container:"+containerName, containers[0].ID+" "+containers[0].Image)
+	return containers[0], nil
+}
+
 func CheckContainersOnSameNetwork(containerNames []string) error {
 	if len(containerNames) < 2 {
 		return nil
@@ -87,7 +150,7 @@ func CheckDockerComposeRunning() error {
 }
 
 func CheckRunningContainers(containerNames []string) error {
-	cmd := exec.Command("docker", "ps", "--format", "{{.Names}}")
+	cmd := exec.Command("docker", "ps", "--no-trunc", "--format", "{{.Names}} {{.ID}} {{.Image}}")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to list running containers: %v", err)
@@ -98,87 +161,23 @@ func CheckRunningContainers(containerNames []string) error {
 		if !strings.Contains(runningContainers, name) {
 			return fmt.Errorf("container %s does not exist", name)
 		}
+		for _, line := range strings.Split(runningContainers, "\n") {
+			if fields := strings.Fields(line); len(fields) == 3 && strings.Contains(fields[0], name) {
+				RecordEvidence("running:"+fields[0], fields[1]+" "+fields[2])
+			}
+		}
 		log.Printf(SpacePrefix+SuccessPrefix+"Container %s exists.\n", name)
 	}
 	return nil
 }
 
-func CheckTodoWebapp(pageURL, scriptURL string) error {
-	titleFound, scriptFound, err := CheckPageContent(pageURL)
-	if err != nil {
-		return fmt.Errorf("error checking todo webapp content: %v", err)
-	}
-
-	if err = CheckHTTPStatus(pageURL, http.StatusOK, ""); err != nil {
-		return fmt.Errorf("todo webapp was not found")
-	}
-
-	if !titleFound {
-		return fmt.Errorf("todo webapp title was not found")
-	}
-
-	if !scriptFound {
-		return fmt.Errorf("script not found in todo webapp")
-	}
-
-	if scriptExists, err := CheckScriptExists(scriptURL); err != nil || !scriptExists {
-		return fmt.Errorf("error checking script URL: %v", err)
+func CheckTodoWebapp(pageURL string, assertions []HTMLAssertion) error {
+	if err := CheckWebPage(pageURL, assertions); err != nil {
+		return fmt.Errorf("error checking todo webapp: %v", err)
 	}
-
 	return nil
 }
 
-func CheckPageContent(url string) (bool, bool, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return false, false, fmt.Errorf("failed to fetch URL: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false, false, fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
-	}
-
-	var titleFound, scriptFound bool
-	z := html.NewTokenizer(resp.Body)
-	for {
-		tt := z.Next()
-		switch tt {
-		case html.ErrorToken:
-			return titleFound, scriptFound, nil
-		case html.StartTagToken, html.SelfClosingTagToken:
-			t := z.Token()
-			if t.Data == "title" {
-				z.Next()
-				if strings.TrimSpace(z.Token().Data) == "Uber To Do" {
-					titleFound = true
-				}
-			}
-			if t.Data == "script" {
-				for _, attr := range t.Attr {
-					if attr.Key == "src" && attr.Val == "/static/js/bundle.js" {
-						scriptFound = true
-					}
-				}
-			}
-		}
-	}
-}
-
-func CheckScriptExists(url string) (bool, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return false, fmt.Errorf(ErrorPrefix+"failed to fetch URL: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf(ErrorPrefix+"received non-200 response code: %d", resp.StatusCode)
-	}
-
-	return true, nil
-}
-
 func CheckHTTPStatus(url string, expectedStatus int, errorMsg string) error {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -189,6 +188,8 @@ func CheckHTTPStatus(url string, expectedStatus int, errorMsg string) error {
 		return fmt.Errorf("%s", errorMsg)
 	}
 	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	RecordEvidence("GET "+url, fmt.Sprintf("%d %s", resp.StatusCode, hashBody(body)))
 
 	if resp.StatusCode == expectedStatus {
 		log.Printf(SpacePrefix+SuccessPrefix+"URL %s returns %d.\n", url, expectedStatus)
@@ -200,10 +201,14 @@ func CheckHTTPStatus(url string, expectedStatus int, errorMsg string) error {
 }
 
 func SendPostRequest(url string, check bool) error {
+	return CreateTodo(url, "grader", check)
+}
+
+func CreateTodo(url, title string, check bool) error {
 	currentTime := time.Now()
 
 	payload := map[string]interface{}{
-		"title":     "grader",
+		"title":     title,
 		"detail":    "check time " + currentTime.String(),
 		"completed": check,
 		"duedate":   currentTime,
@@ -241,6 +246,7 @@ func SendGetRequest(url, word string) error {
 	if err != nil {
 		return fmt.Errorf("error reading response body: %v", err)
 	}
+	RecordEvidence("GET "+url, fmt.Sprintf("%d %s", resp.StatusCode, hashBody(body)))
 
 	if strings.Contains(string(body), word) {
 		return nil
@@ -264,8 +270,14 @@ func CheckNamespaceExists(namespace string) error {
 	return fmt.Errorf("Couldn't find namespace %v.", err)
 }
 
-func CheckKubernetesResources(namespace string) error {
-	wordsToFind := []string{"service/todo", "deployment.apps/todo", "pod/todo", "Running", "80"}
+func CheckKubernetesResources(namespace string, expectation KubernetesExpectation) error {
+	wordsToFind := []string{
+		"service/" + expectation.Name,
+		"deployment.apps/" + expectation.Name,
+		"pod/" + expectation.Name,
+		"Running",
+		strconv.Itoa(expectation.ServicePort),
+	}
 
 	cmd := exec.Command("kubectl", "get", "all", "-n", namespace)
 	output, err := cmd.Output()
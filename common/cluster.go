@@ -0,0 +1,127 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+const (
+	FlavorKind          = "kind"
+	FlavorMinikube      = "minikube"
+	FlavorDockerDesktop = "Docker Desktop"
+	FlavorK3d           = "k3d"
+	FlavorUnknown       = "unknown"
+)
+
+type ClusterInfo struct {
+	Context string
+	Server  string
+	Version string
+	Flavor  string
+}
+
+func (c ClusterInfo) String() string {
+	if c.Context == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s, %s)", c.Context, c.Flavor, c.Version)
+}
+
+func DetectKubernetesCluster() (ClusterInfo, error) {
+	var info ClusterInfo
+
+	output, err := exec.Command("kubectl", "config", "current-context").Output()
+	if err != nil {
+		return info, fmt.Errorf("failed to get current kubectl context: %v", err)
+	}
+	info.Context = strings.TrimSpace(string(output))
+
+	output, err = exec.Command("kubectl", "config", "view", "--minify", "-o", "jsonpath={.clusters[0].cluster.server}").Output()
+	if err != nil {
+		return info, fmt.Errorf("failed to get cluster server: %v", err)
+	}
+	info.Server = strings.TrimSpace(string(output))
+
+	output, err = exec.Command("kubectl", "version", "-o", "json").Output()
+	if err != nil {
+		return info, fmt.Errorf("failed to get cluster version: %v", err)
+	}
+	var version struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(output, &version); err != nil {
+		return info, fmt.Errorf("failed to parse kubectl version: %v", err)
+	}
+	info.Version = version.ServerVersion.GitVersion
+	info.Flavor = detectClusterFlavor(info)
+
+	return info, nil
+}
+
+func detectClusterFlavor(info ClusterInfo) string {
+	switch {
+	case strings.HasPrefix(info.Context, "kind-"):
+		return FlavorKind
+	case strings.HasPrefix(info.Context, "k3d-"), strings.Contains(info.Version, "+k3s"):
+		return FlavorK3d
+	case info.Context == "minikube" || strings.Contains(info.Version, "minikube"):
+		return FlavorMinikube
+	case info.Context == "docker-desktop" || info.Context == "docker-for-desktop" || strings.Contains(info.Server, "kubernetes.docker.internal"):
+		return FlavorDockerDesktop
+	}
+	return FlavorUnknown
+}
+
+func isLocalServer(server string) bool {
+	u, err := url.Parse(server)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".docker.internal") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified())
+}
+
+// isProductionLooking reports whether a segment of the context name, such as
+// "prod" in "team-prod" or "prd.eu", marks a production cluster.
+func isProductionLooking(info ClusterInfo) bool {
+	segments := strings.FieldsFunc(strings.ToLower(info.Context), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, segment := range segments {
+		if segment == "prod" || segment == "prd" || segment == "production" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckClusterSafety decides whether disruptive checks may run against the
+// cluster. allowedContext skips the checks for a context the student has
+// explicitly allowed.
+func CheckClusterSafety(info ClusterInfo, allowedContext string) error {
+	if allowedContext != "" && info.Context == allowedContext {
+		return nil
+	}
+	if isProductionLooking(info) {
+		return fmt.Errorf("current context %s (%s) looks like a production cluster. Please switch to your own cluster or allow it with -allow-cluster=%s", info.Context, info.Server, info.Context)
+	}
+	if info.Flavor == FlavorUnknown && !isLocalServer(info.Server) {
+		log.Printf(ErrorPrefix+"Current context %s points at a remote cluster %s.\n", info.Context, info.Server)
+		if !ConfirmAction("Do you really want to run disruptive checks against this cluster?") {
+			return fmt.Errorf("disruptive checks against remote cluster %s were cancelled", info.Server)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ComposeExpectation struct {
+	Project      string
+	Services     []string
+	Networks     []string
+	AllowedPorts []int
+}
+
+var ComposeExpectations = map[string]ComposeExpectation{
+	"activity2": {
+		Project:      "monitoring",
+		Services:     []string{"grafana", "prometheus", "node-exporter"},
+		AllowedPorts: []int{3000, 9090},
+	},
+	"activity3": {
+		Services:     []string{"webapp", "todo-service", "notification-service", "redis", "api-gateway"},
+		Networks:     []string{"todo-net"},
+		AllowedPorts: []int{3000, 8000},
+	},
+}
+
+type ComposeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]ComposeService `yaml:"services"`
+	Networks map[string]yaml.Node      `yaml:"networks"`
+	Volumes  map[string]yaml.Node      `yaml:"volumes"`
+}
+
+type ComposeService struct {
+	Image     string      `yaml:"image"`
+	Build     yaml.Node   `yaml:"build"`
+	Ports     []yaml.Node `yaml:"ports"`
+	Networks  yaml.Node   `yaml:"networks"`
+	DependsOn yaml.Node   `yaml:"depends_on"`
+	Volumes   []yaml.Node `yaml:"volumes"`
+}
+
+func LoadComposeFile(path string) (*ComposeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file %s: %v", path, err)
+	}
+
+	var compose ComposeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file %s: %v", path, err)
+	}
+	if len(compose.Services) == 0 {
+		return nil, fmt.Errorf("compose file %s has no services", path)
+	}
+	return &compose, nil
+}
+
+func LintComposeFile(path string, expectation ComposeExpectation) []bool {
+	compose, err := LoadComposeFile(path)
+	if !CheckResult(err, fmt.Sprintf("Compose file %s can be parsed.", path)) {
+		return []bool{false}
+	}
+
+	return []bool{
+		CheckResult(CheckComposeServices(compose, expectation.Services), "All required services are declared."),
+		CheckResult(CheckComposeNetworks(compose, expectation.Networks), "Networks are declared and attached to all services."),
+		CheckResult(CheckComposePorts(compose, expectation.AllowedPorts), "Only allowed ports are published."),
+		CheckResult(CheckComposeDependsOn(compose), "depends_on references existing services."),
+		CheckResult(CheckComposeVolumes(compose), "Named volumes are declared."),
+		CheckResult(CheckComposeImageTags(compose), "All images are pinned to a tag."),
+	}
+}
+
+func (c *ComposeFile) serviceNames() []string {
+	var names []string
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nodeKeys returns the entries of a compose field that may be written either
+// as a list ("- name") or as a map ("name: {...}").
+func nodeKeys(node yaml.Node) []string {
+	var keys []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			keys = append(keys, item.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			keys = append(keys, node.Content[i].Value)
+		}
+	}
+	return keys
+}
+
+func CheckComposeServices(compose *ComposeFile, services []string) error {
+	var errs []error
+	for _, name := range services {
+		if _, ok := compose.Services[name]; !ok {
+			errs = append(errs, fmt.Errorf("service %s is not declared", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckComposeNetworks(compose *ComposeFile, networks []string) error {
+	var errs []error
+	for _, network := range networks {
+		if _, ok := compose.Networks[network]; !ok {
+			errs = append(errs, fmt.Errorf("network %s is not declared", network))
+		}
+	}
+
+	for _, name := range compose.serviceNames() {
+		attached := nodeKeys(compose.Services[name].Networks)
+		for _, network := range attached {
+			if _, ok := compose.Networks[network]; !ok && network != "default" {
+				errs = append(errs, fmt.Errorf("service %s uses undeclared network %s", name, network))
+			}
+		}
+		for _, network := range networks {
+			if !containsString(attached, network) {
+				errs = append(errs, fmt.Errorf("service %s is not attached to network %s", name, network))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishedPort returns the host port of a short ("8000:80", "127.0.0.1:8000:80",
+// "[::1]:8000:80") or long ({target: 80, published: 8000}) port definition,
+// or 0 if the host port is not fixed. For a range, the first port is returned.
+func publishedPort(node yaml.Node) (int, error) {
+	if node.Kind == yaml.MappingNode {
+		var port struct {
+			Published string `yaml:"published"`
+		}
+		if err := node.Decode(&port); err != nil {
+			return 0, err
+		}
+		return parseHostPort(port.Published)
+	}
+
+	spec := strings.SplitN(node.Value, "/", 2)[0]
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return 0, nil
+	}
+	return parseHostPort(parts[len(parts)-2])
+}
+
+func parseHostPort(host string) (int, error) {
+	if i := strings.Index(host, "-"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(host)
+}
+
+// describePort formats a port definition for error messages. Long syntax
+// ports have no scalar value, so their fields are shown instead.
+func describePort(node yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Sprintf("%q", node.Value)
+	}
+	var fields []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fields = append(fields, node.Content[i].Value+": "+node.Content[i+1].Value)
+	}
+	return fmt.Sprintf("{%s} (line %d)", strings.Join(fields, ", "), node.Line)
+}
+
+func CheckComposePorts(compose *ComposeFile, allowedPorts []int) error {
+	var errs []error
+	for _, name := range compose.serviceNames() {
+		for _, node := range compose.Services[name].Ports {
+			port, err := publishedPort(node)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("service %s has an invalid port %s: %v", name, describePort(node), err))
+				continue
+			}
+			if port != 0 && !containsInt(allowedPorts, port) {
+				errs = append(errs, fmt.Errorf("service %s publishes port %d, only %v are allowed", name, port, allowedPorts))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckComposeDependsOn(compose *ComposeFile) error {
+	var errs []error
+	for _, name := range compose.serviceNames() {
+		for _, dependency := range nodeKeys(compose.Services[name].DependsOn) {
+			if _, ok := compose.Services[dependency]; !ok {
+				errs = append(errs, fmt.Errorf("service %s depends on unknown service %s", name, dependency))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckComposeVolumes(compose *ComposeFile) error {
+	var errs []error
+	for _, name := range compose.serviceNames() {
+		for _, node := range compose.Services[name].Volumes {
+			var source string
+			if node.Kind == yaml.MappingNode {
+				var volume struct {
+					Type   string `yaml:"type"`
+					Source string `yaml:"source"`
+				}
+				if err := node.Decode(&volume); err != nil || volume.Type != "volume" {
+					continue
+				}
+				source = volume.Source
+			} else {
+				source = strings.SplitN(node.Value, ":", 2)[0]
+				if !strings.Contains(node.Value, ":") || strings.ContainsAny(source, "/.~$") {
+					continue
+				}
+			}
+			if _, ok := compose.Volumes[source]; source != "" && !ok {
+				errs = append(errs, fmt.Errorf("service %s uses undeclared volume %s", name, source))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckComposeImageTags(compose *ComposeFile) error {
+	var errs []error
+	for _, name := range compose.serviceNames() {
+		service := compose.Services[name]
+		if service.Image == "" {
+			if service.Build.Kind == 0 {
+				errs = append(errs, fmt.Errorf("service %s has neither image nor build", name))
+			}
+			continue
+		}
+		if service.Build.Kind != 0 || strings.Contains(service.Image, "@sha256:") {
+			continue
+		}
+		image := service.Image[strings.LastIndex(service.Image, "/")+1:]
+		if !strings.Contains(image, ":") || strings.HasSuffix(image, ":latest") {
+			errs = append(errs, fmt.Errorf("service %s image %s is not pinned to a version tag", name, service.Image))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type ComposeProject struct {
+	Name        string `json:"Name"`
+	Status      string `json:"Status"`
+	ConfigFiles string `json:"ConfigFiles"`
+}
+
+type ComposeContainer struct {
+	Name    string `json:"Name"`
+	Service string `json:"Service"`
+	State   string `json:"State"`
+}
+
+// decodeJSONList accepts both a JSON array and JSON lines, since the output
+// of 'docker compose ps --format json' changed between compose versions.
+func decodeJSONList[T any](output []byte) ([]T, error) {
+	var list []T
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 {
+		return list, nil
+	}
+	if trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &list)
+		return list, err
+	}
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		var item T
+		if err := json.Unmarshal(line, &item); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func GetComposeProject(name string) (ComposeProject, error) {
+	output, err := exec.Command("docker", "compose", "ls", "--all", "--format", "json").Output()
+	if err != nil {
+		return ComposeProject{}, fmt.Errorf("failed to run 'docker compose ls': %v", err)
+	}
+
+	projects, err := decodeJSONList[ComposeProject](output)
+	if err != nil {
+		return ComposeProject{}, fmt.Errorf("failed to parse 'docker compose ls' output: %v", err)
+	}
+
+	var names []string
+	for _, project := range projects {
+		if project.Name == name {
+			return project, nil
+		}
+		names = append(names, project.Name)
+	}
+	return ComposeProject{}, fmt.Errorf("docker compose project %s not found (found: %s)", name, strings.Join(names, ", "))
+}
+
+func ComposeProjectOfService(service string) (string, error) {
+	cmd := exec.Command("docker", "ps", "-a", "--filter", "label=com.docker.compose.service="+service, "--format", `{{.Label "com.docker.compose.project"}}`)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %v", err)
+	}
+	projects := strings.Fields(string(output))
+	if len(projects) == 0 {
+		return "", fmt.Errorf("service %s is not started by docker compose", service)
+	}
+	return projects[0], nil
+}
+
+func CheckComposeProject(expectation ComposeExpectation) error {
+	name := expectation.Project
+	if name == "" && len(expectation.Services) > 0 {
+		var err error
+		if name, err = ComposeProjectOfService(expectation.Services[0]); err != nil {
+			return err
+		}
+	}
+
+	project, err := GetComposeProject(name)
+	if err != nil {
+		return err
+	}
+
+	configFiles := strings.Split(project.ConfigFiles, ",")
+	for _, file := range configFiles {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("config file %s of compose project %s does not exist", file, name)
+		}
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Compose project %s uses %s.\n", name, project.ConfigFiles)
+
+	args := []string{"compose", "-p", name}
+	for _, file := range configFiles {
+		args = append(args, "-f", file)
+	}
+	output, err := exec.Command("docker", append(args, "config", "--services")...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to read services of compose project %s: %v", name, err)
+	}
+	declared := strings.Fields(string(output))
+
+	output, err = exec.Command("docker", "compose", "-p", name, "ps", "--format", "json").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list containers of compose project %s: %v", name, err)
+	}
+	containers, err := decodeJSONList[ComposeContainer](output)
+	if err != nil {
+		return fmt.Errorf("failed to parse 'docker compose ps' output: %v", err)
+	}
+
+	var running []string
+	for _, container := range containers {
+		if container.State == "running" && !containsString(running, container.Service) {
+			running = append(running, container.Service)
+		}
+	}
+
+	for _, service := range append(declared, expectation.Services...) {
+		if !containsString(running, service) {
+			return fmt.Errorf("compose project %s has %d of %d services running. Service %s is not running", name, len(running), len(declared), service)
+		}
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Compose project %s has all %d services running.\n", name, len(declared))
+	return nil
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPublishedPort(t *testing.T) {
+	tests := []struct {
+		ports   string
+		want    int
+		wantErr bool
+	}{
+		{ports: `"3000"`, want: 0},
+		{ports: `3000`, want: 0},
+		{ports: `"8000:80"`, want: 8000},
+		{ports: `"8000:80/udp"`, want: 8000},
+		{ports: `"8000-8010:80-90"`, want: 8000},
+		{ports: `"127.0.0.1:8000:80"`, want: 8000},
+		{ports: `"127.0.0.1::80"`, want: 0},
+		{ports: `"[::1]:8000:80"`, want: 8000},
+		{ports: `"[::]:9090:9090"`, want: 9090},
+		{ports: `"[::1]::80"`, want: 0},
+		{ports: `"${PORT}:80"`, wantErr: true},
+		{ports: `{target: 80, published: 8000}`, want: 8000},
+		{ports: `{target: 80, published: "8000"}`, want: 8000},
+		{ports: `{target: 80, published: "8000-8010"}`, want: 8000},
+		{ports: `{target: 80, host_ip: "::1", published: 3000, protocol: tcp}`, want: 3000},
+		{ports: `{target: 80}`, want: 0},
+		{ports: `{target: 80, published: abc}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ports, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.ports), &doc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := publishedPort(*doc.Content[0])
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("publishedPort(%s) = %d, want an error", tt.ports, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("publishedPort(%s) = %d, %v, want %d", tt.ports, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckComposePortsInvalid(t *testing.T) {
+	tests := []struct {
+		ports string
+		want  string
+	}{
+		{ports: `"${PORT}:80"`, want: `service web has an invalid port "${PORT}:80"`},
+		{ports: `{target: 80, published: abc}`, want: `service web has an invalid port {target: 80, published: abc} (line 5)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ports, func(t *testing.T) {
+			var compose ComposeFile
+			data := "services:\n  web:\n    image: nginx:1.27\n    ports:\n      - " + tt.ports + "\n"
+			if err := yaml.Unmarshal([]byte(data), &compose); err != nil {
+				t.Fatal(err)
+			}
+			err := CheckComposePorts(&compose, []int{8000})
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("CheckComposePorts() = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
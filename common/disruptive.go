@@ -0,0 +1,216 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Minute
+
+// AllowDisruptive reports whether checks that restart or kill services may
+// run, asking the student when the --allow-disruptive flag was not given.
+func AllowDisruptive(allowed bool) bool {
+	if allowed {
+		return true
+	}
+	if ConfirmAction("Some checks restart your services. Run disruptive checks?") {
+		return true
+	}
+	log.Println(SpacePrefix + "Skipping disruptive checks.")
+	return false
+}
+
+func RestartContainer(containerName string) error {
+	if output, err := exec.Command("docker", "restart", containerName).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restart container %s: %v %s", containerName, err, strings.TrimSpace(string(output)))
+	}
+	return WaitForContainerReady(containerName, readinessTimeout)
+}
+
+func WaitForContainerReady(containerName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		info, err := InspectContainer(containerName)
+		if err == nil && info.State.Running && (info.State.Health == nil || info.State.Health.Status == "healthy") {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("container %s is not ready after %s", containerName, timeout)
+}
+
+type Pod struct {
+	Name  string
+	UID   string
+	Ready bool
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string `json:"name"`
+			UID               string `json:"uid"`
+			DeletionTimestamp string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// parsePods decodes kubectl get pods output, skipping pods that are being
+// deleted.
+func parsePods(output []byte) ([]Pod, error) {
+	var list podList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pod list: %v", err)
+	}
+
+	var pods []Pod
+	for _, item := range list.Items {
+		if item.Metadata.DeletionTimestamp != "" {
+			continue
+		}
+		pod := Pod{Name: item.Metadata.Name, UID: item.Metadata.UID}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				pod.Ready = true
+			}
+		}
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// ServiceSelector returns the label selector of a service, e.g. "app=redis",
+// so that the pods behind it can be found whatever they are named.
+func ServiceSelector(namespace, service string) (string, error) {
+	output, err := exec.Command("kubectl", "get", "service", service, "-n", namespace, "-o", "jsonpath={.spec.selector}").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get service %s in namespace %s: %v", service, namespace, err)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(output, &labels); err != nil || len(labels) == 0 {
+		return "", fmt.Errorf("service %s in namespace %s has no pod selector", service, namespace)
+	}
+	var selector []string
+	for key, value := range labels {
+		selector = append(selector, key+"="+value)
+	}
+	sort.Strings(selector)
+	return strings.Join(selector, ","), nil
+}
+
+// GetServicePods returns the pods selected by a service.
+func GetServicePods(namespace, service string) ([]Pod, string, error) {
+	selector, err := ServiceSelector(namespace, service)
+	if err != nil {
+		return nil, "", err
+	}
+	pods, err := GetPods(namespace, selector)
+	if err == nil && len(pods) == 0 {
+		err = fmt.Errorf("no pod of service %s found in namespace %s", service, namespace)
+	}
+	return pods, selector, err
+}
+
+func GetPods(namespace, selector string) ([]Pod, error) {
+	output, err := exec.Command("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute kubectl command: %v", err)
+	}
+	return parsePods(output)
+}
+
+// RestartPods deletes the pods of a service and waits for a replacement.
+func RestartPods(namespace, service string) error {
+	pods, selector, err := GetServicePods(namespace, service)
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		if output, err := exec.Command("kubectl", "delete", "pod", pod.Name, "-n", namespace, "--wait=false").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to delete pod %s: %v %s", pod.Name, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return WaitForPodsReady(namespace, selector, pods, readinessTimeout)
+}
+
+// replacementReady reports whether a pod that is not in previous is ready.
+// Pods are compared by UID, since StatefulSet pods keep their name.
+func replacementReady(pods, previous []Pod) bool {
+	old := map[string]bool{}
+	for _, pod := range previous {
+		old[pod.UID] = true
+	}
+	for _, pod := range pods {
+		if pod.Ready && !old[pod.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitForPodsReady waits until a pod matching selector that is not in
+// previous is ready.
+func WaitForPodsReady(namespace, selector string, previous []Pod, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		pods, err := GetPods(namespace, selector)
+		if err == nil && replacementReady(pods, previous) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("no new pod matching %s is ready after %s", selector, timeout)
+}
+
+func WaitForHTTPStatus(url string, expectedStatus int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == expectedStatus {
+				return nil
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("URL %s does not return %d after %s", url, expectedStatus, timeout)
+}
+
+func CheckTodoPersistence(url string, restart func() error) error {
+	title := fmt.Sprintf("grader-persistence-%d", time.Now().Unix())
+	if err := CreateTodo(url, title, false); err != nil {
+		return err
+	}
+	if err := SendGetRequest(url, title); err != nil {
+		return err
+	}
+
+	log.Println(SpacePrefix + "Restarting redis...")
+	if err := restart(); err != nil {
+		return err
+	}
+	if err := WaitForHTTPStatus(url, http.StatusOK, readinessTimeout); err != nil {
+		return err
+	}
+
+	if err := SendGetRequest(url, title); err != nil {
+		return fmt.Errorf("todo %s was lost after redis restarted. Please check your redis volume", title)
+	}
+	return nil
+}
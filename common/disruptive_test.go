@@ -0,0 +1,39 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+const podListJSON = `{"items": [
+	{"metadata": {"name": "redis-0", "uid": "%s"}, "status": {"conditions": [{"type": "Ready", "status": "%s"}]}},
+	{"metadata": {"name": "redis-1", "uid": "b", "deletionTimestamp": "2024-01-01T00:00:00Z"}, "status": {"conditions": [{"type": "Ready", "status": "True"}]}}
+]}`
+
+func TestReplacementReady(t *testing.T) {
+	previous := []Pod{{Name: "redis-0", UID: "a", Ready: true}}
+	tests := []struct {
+		name  string
+		uid   string
+		ready string
+		want  bool
+	}{
+		{name: "old pod still running", uid: "a", ready: "True", want: false},
+		{name: "same name replacement not ready", uid: "c", ready: "False", want: false},
+		{name: "same name replacement ready", uid: "c", ready: "True", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods, err := parsePods([]byte(fmt.Sprintf(podListJSON, tt.uid, tt.ready)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pods) != 1 {
+				t.Fatalf("parsePods() = %v, want only redis-0", pods)
+			}
+			if got := replacementReady(pods, previous); got != tt.want {
+				t.Errorf("replacementReady(%v) = %v, want %v", pods, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+type CheckRecord struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+}
+
+// Checks and evidence recorded while grading. They are included in the
+// submission so that a signed row shows what was actually checked.
+var (
+	recordMu     sync.Mutex
+	checkRecords []CheckRecord
+	evidence     = map[string]string{}
+)
+
+func RecordCheck(name string, passed bool) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	checkRecords = append(checkRecords, CheckRecord{Name: name, Passed: passed})
+}
+
+// RecordEvidence stores an observation such as a container ID or a response
+// hash. A later value for the same key replaces the earlier one.
+func RecordEvidence(key, value string) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	evidence[key] = value
+}
+
+func RecordedChecks() []CheckRecord {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	return append([]CheckRecord{}, checkRecords...)
+}
+
+// EvidenceDigest hashes the recorded evidence in key order.
+func EvidenceDigest() string {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	keys := make([]string, 0, len(evidence))
+	for key := range evidence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key + "=" + evidence[key] + "\n"))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const traceHeader = "X-Grader-Trace"
+
+type GatewayRoute struct {
+	Path      string
+	Container string
+}
+
+// SendTracedRequest sends a GET request tagged with id in both a header and
+// the query string, so that it can be found in backend access logs. It
+// returns the status code and the response body and headers as text.
+func SendTracedRequest(url, id string) (int, string, error) {
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	req, err := http.NewRequest(http.MethodGet, url+separator+"grader_trace="+id, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set(traceHeader, id)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("error sending GET request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, "", fmt.Errorf("error reading response body: %v", err)
+	}
+	return resp.StatusCode, string(body) + fmt.Sprint(resp.Header), nil
+}
+
+func ContainerLogsContain(containerName string, since time.Time, word string) (bool, error) {
+	cmd := exec.Command("docker", "logs", "--since", since.Format(time.RFC3339), containerName)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to read logs of container %s: %v", containerName, err)
+	}
+	return strings.Contains(string(output), word), nil
+}
+
+// containerIdentity returns the hostname and short ID of a container, one of
+// which a backend that echoes its identity would include in the response.
+func containerIdentity(containerName string) []string {
+	info, err := InspectContainer(containerName)
+	if err != nil {
+		return nil
+	}
+	identity := []string{shortID(info.ID)}
+	if info.Config.Hostname != "" {
+		identity = append(identity, info.Config.Hostname)
+	}
+	return identity
+}
+
+// CheckGatewayRouting sends a traced request to every route, expects 200 for
+// each of them and 404 for an unknown path. With confirmBackends, a route
+// also has to show up in the logs of its container, or the response has to
+// echo the trace together with that container's hostname or ID. This depends
+// on how the backends log, so it is opt-in.
+func CheckGatewayRouting(gatewayURL string, routes []GatewayRoute, confirmBackends bool) error {
+	for _, route := range routes {
+		id := fmt.Sprintf("grader-%d", time.Now().UnixNano())
+		since := time.Now().Add(-time.Second)
+
+		status, response, err := SendTracedRequest(gatewayURL+route.Path, id)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("route %s returns %d through the gateway", route.Path, status)
+		}
+		if !confirmBackends {
+			log.Printf(SpacePrefix+SuccessPrefix+"Route %s returns %d.\n", route.Path, status)
+			continue
+		}
+
+		// Access logs may be flushed a little after the response is sent.
+		var hitBy []string
+		for attempt := 0; attempt < 5 && len(hitBy) == 0; attempt++ {
+			time.Sleep(time.Second)
+			for _, other := range routes {
+				if found, err := ContainerLogsContain(other.Container, since, id); err == nil && found && !containsString(hitBy, other.Container) {
+					hitBy = append(hitBy, other.Container)
+				}
+			}
+		}
+
+		switch {
+		case containsString(hitBy, route.Container):
+			log.Printf(SpacePrefix+SuccessPrefix+"Route %s reaches %s.\n", route.Path, route.Container)
+			continue
+		case len(hitBy) > 0:
+			return fmt.Errorf("route %s reaches %s instead of %s", route.Path, strings.Join(hitBy, ", "), route.Container)
+		}
+
+		if strings.Contains(response, id) {
+			echoed := false
+			for _, identity := range containerIdentity(route.Container) {
+				if strings.Contains(response, identity) {
+					echoed = true
+				}
+			}
+			if echoed {
+				log.Printf(SpacePrefix+SuccessPrefix+"Route %s is answered by %s.\n", route.Path, route.Container)
+				continue
+			}
+		}
+		return fmt.Errorf("could not confirm that route %s reaches %s. Please make %s log incoming requests", route.Path, route.Container, route.Container)
+	}
+
+	unknownPath := fmt.Sprintf("/grader-unknown-%d", time.Now().UnixNano())
+	status, _, err := SendTracedRequest(gatewayURL+unknownPath, "unknown")
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNotFound {
+		return fmt.Errorf("unknown path %s returns %d through the gateway, expected %d", unknownPath, status, http.StatusNotFound)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Unknown path %s returns %d.\n", unknownPath, http.StatusNotFound)
+	return nil
+}
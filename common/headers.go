@@ -0,0 +1,138 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderPresent = iota
+	HeaderEquals
+	HeaderContains
+)
+
+// ExpectedHeader describes a response header. With HeaderContains, Value is
+// a comma separated list of tokens that must all appear in the header.
+type ExpectedHeader struct {
+	Name  string
+	Value string
+	Mode  int
+}
+
+var DefaultSecurityHeaders = []ExpectedHeader{
+	{Name: "X-Content-Type-Options", Value: "nosniff", Mode: HeaderEquals},
+	{Name: "X-Frame-Options", Mode: HeaderPresent},
+	{Name: "Referrer-Policy", Mode: HeaderPresent},
+	{Name: "Content-Security-Policy", Mode: HeaderPresent},
+}
+
+func headerTokens(value string) []string {
+	var tokens []string
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func (e ExpectedHeader) matches(actual string, present bool) bool {
+	switch e.Mode {
+	case HeaderEquals:
+		return present && strings.EqualFold(strings.TrimSpace(actual), e.Value)
+	case HeaderContains:
+		tokens := headerTokens(actual)
+		if containsString(tokens, "*") {
+			return true
+		}
+		for _, want := range headerTokens(e.Value) {
+			if !containsString(tokens, want) {
+				return false
+			}
+		}
+		return present
+	}
+	return present
+}
+
+func (e ExpectedHeader) String() string {
+	switch e.Mode {
+	case HeaderEquals:
+		return e.Name + ": " + e.Value
+	case HeaderContains:
+		return e.Name + ": (contains) " + e.Value
+	}
+	return e.Name + ": (any value)"
+}
+
+// DiffHeaders returns one "- expected / + actual" pair for every header in
+// expected that the response does not satisfy.
+func DiffHeaders(actual http.Header, expected []ExpectedHeader) []string {
+	var diff []string
+	for _, e := range expected {
+		values, present := actual[http.CanonicalHeaderKey(e.Name)]
+		value := strings.Join(values, ", ")
+		if e.matches(value, present) {
+			continue
+		}
+		diff = append(diff, "- "+e.String())
+		if present {
+			diff = append(diff, "+ "+e.Name+": "+value)
+		} else {
+			diff = append(diff, "+ "+e.Name+" (missing)")
+		}
+	}
+	return diff
+}
+
+func headerDiffError(message string, diff []string) error {
+	return fmt.Errorf("%s:\n%s%s", message, SpacePrefix, strings.Join(diff, "\n"+SpacePrefix))
+}
+
+func CheckCORS(url, origin, method string, requestHeaders []string) error {
+	resp, err := SendPreflight(url, origin, method, requestHeaders)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("CORS preflight to %s from %s returns %d", url, origin, resp.StatusCode)
+	}
+
+	allowOrigin := ExpectedHeader{Name: "Access-Control-Allow-Origin", Value: origin, Mode: HeaderEquals}
+	if resp.Header.Get("Access-Control-Allow-Origin") == "*" {
+		allowOrigin.Value = "*"
+	}
+	expected := []ExpectedHeader{allowOrigin}
+	// Browsers allow CORS-safelisted methods without Access-Control-Allow-Methods.
+	if !containsString([]string{http.MethodGet, http.MethodHead, http.MethodPost}, strings.ToUpper(method)) {
+		expected = append(expected, ExpectedHeader{Name: "Access-Control-Allow-Methods", Value: method, Mode: HeaderContains})
+	}
+	if len(requestHeaders) > 0 {
+		expected = append(expected, ExpectedHeader{Name: "Access-Control-Allow-Headers", Value: strings.Join(requestHeaders, ","), Mode: HeaderContains})
+	}
+
+	if diff := DiffHeaders(resp.Header, expected); len(diff) > 0 {
+		return headerDiffError(fmt.Sprintf("CORS preflight to %s from %s has unexpected headers", url, origin), diff)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"CORS preflight to %s allows %s %s.\n", url, method, origin)
+	return nil
+}
+
+func CheckSecurityHeaders(url string, expected []ExpectedHeader) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("error sending GET request: %v", err)
+	}
+	resp.Body.Close()
+
+	if strings.HasPrefix(url, "https://") {
+		expected = append(append([]ExpectedHeader{}, expected...), ExpectedHeader{Name: "Strict-Transport-Security", Mode: HeaderPresent})
+	}
+	if diff := DiffHeaders(resp.Header, expected); len(diff) > 0 {
+		return headerDiffError(fmt.Sprintf("%s is missing security headers", url), diff)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"%s sends all security headers.\n", url)
+	return nil
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDiffHeaders(t *testing.T) {
+	allowHeaders := ExpectedHeader{Name: "Access-Control-Allow-Headers", Value: "content-type,authorization", Mode: HeaderContains}
+	tests := []struct {
+		name     string
+		actual   http.Header
+		expected ExpectedHeader
+		want     []string
+	}{
+		{
+			name:     "contains all tokens in another order",
+			actual:   http.Header{"Access-Control-Allow-Headers": {"Authorization, Content-Type"}},
+			expected: allowHeaders,
+		},
+		{
+			name:     "contains tokens across repeated headers",
+			actual:   http.Header{"Access-Control-Allow-Headers": {"content-type", "authorization"}},
+			expected: allowHeaders,
+		},
+		{
+			name:     "contains wildcard",
+			actual:   http.Header{"Access-Control-Allow-Headers": {"*"}},
+			expected: allowHeaders,
+		},
+		{
+			name:     "contains a missing token",
+			actual:   http.Header{"Access-Control-Allow-Headers": {"content-type"}},
+			expected: allowHeaders,
+			want:     []string{"- Access-Control-Allow-Headers: (contains) content-type,authorization", "+ Access-Control-Allow-Headers: content-type"},
+		},
+		{
+			name:     "contains a token only as a substring",
+			actual:   http.Header{"Access-Control-Allow-Headers": {"x-content-type, authorization-token"}},
+			expected: allowHeaders,
+			want:     []string{"- Access-Control-Allow-Headers: (contains) content-type,authorization", "+ Access-Control-Allow-Headers: x-content-type, authorization-token"},
+		},
+		{
+			name:     "contains but missing header",
+			actual:   http.Header{},
+			expected: allowHeaders,
+			want:     []string{"- Access-Control-Allow-Headers: (contains) content-type,authorization", "+ Access-Control-Allow-Headers (missing)"},
+		},
+		{
+			name:     "equals ignores case",
+			actual:   http.Header{"X-Content-Type-Options": {"NoSniff"}},
+			expected: ExpectedHeader{Name: "X-Content-Type-Options", Value: "nosniff", Mode: HeaderEquals},
+		},
+		{
+			name:     "equals mismatch",
+			actual:   http.Header{"Access-Control-Allow-Origin": {"http://evil.example"}},
+			expected: ExpectedHeader{Name: "Access-Control-Allow-Origin", Value: "http://localhost:3000", Mode: HeaderEquals},
+			want:     []string{"- Access-Control-Allow-Origin: http://localhost:3000", "+ Access-Control-Allow-Origin: http://evil.example"},
+		},
+		{
+			name:     "present with empty value",
+			actual:   http.Header{"Referrer-Policy": {""}},
+			expected: ExpectedHeader{Name: "referrer-policy", Mode: HeaderPresent},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffHeaders(tt.actual, []ExpectedHeader{tt.expected})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffHeaders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
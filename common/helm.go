@@ -0,0 +1,261 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type HelmRelease struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Revision   string `json:"revision"`
+	Status     string `json:"status"`
+	Chart      string `json:"chart"`
+	AppVersion string `json:"app_version"`
+}
+
+// ChartName splits the "<name>-<version>" chart string reported by helm list.
+func (r HelmRelease) ChartName() (string, string) {
+	for i := len(r.Chart) - 1; i > 0; i-- {
+		if r.Chart[i] == '-' && i+1 < len(r.Chart) && r.Chart[i+1] >= '0' && r.Chart[i+1] <= '9' {
+			return r.Chart[:i], r.Chart[i+1:]
+		}
+	}
+	return r.Chart, ""
+}
+
+func GetHelmRelease(namespace, name string) (HelmRelease, error) {
+	cmd := exec.Command("helm", "list", "-n", namespace, "--all", "-o", "json", "--filter", "^"+name+"$")
+	output, err := cmd.Output()
+	if err != nil {
+		return HelmRelease{}, fmt.Errorf("failed to execute helm command: %v", err)
+	}
+
+	var releases []HelmRelease
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return HelmRelease{}, fmt.Errorf("failed to parse helm list output: %v", err)
+	}
+	for _, release := range releases {
+		if release.Name == name {
+			return release, nil
+		}
+	}
+	return HelmRelease{}, fmt.Errorf("helm release %s not found in namespace %s", name, namespace)
+}
+
+func CheckHelmRelease(namespace, name, chartName, versionRange string) error {
+	release, err := GetHelmRelease(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if release.Status != "deployed" {
+		return fmt.Errorf("helm release %s has status %s, expected deployed", name, release.Status)
+	}
+
+	chart, version := release.ChartName()
+	if chartName != "" && chart != chartName {
+		return fmt.Errorf("helm release %s uses chart %s, expected %s", name, chart, chartName)
+	}
+
+	if versionRange != "" {
+		ok, err := VersionInRange(version, versionRange)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("helm release %s uses chart version %s, expected %s", name, version, versionRange)
+		}
+	}
+
+	log.Printf(SpacePrefix+SuccessPrefix+"Helm release %s is deployed with chart %s.\n", name, release.Chart)
+	return nil
+}
+
+func CheckHelmValue(namespace, release, path string, expected interface{}) error {
+	cmd := exec.Command("helm", "get", "values", release, "-n", namespace, "-o", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to get values of helm release %s: %v", release, err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(output, &values); err != nil {
+		return fmt.Errorf("failed to parse values of helm release %s: %v", release, err)
+	}
+
+	var current interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %s is not set in helm release %s", path, release)
+		}
+		if current, ok = m[key]; !ok {
+			return fmt.Errorf("value %s is not set in helm release %s", path, release)
+		}
+	}
+
+	if fmt.Sprint(current) != fmt.Sprint(expected) {
+		return fmt.Errorf("value %s of helm release %s is %v, expected %v", path, release, current, expected)
+	}
+
+	log.Printf(SpacePrefix+SuccessPrefix+"Helm release %s has %s=%v.\n", release, path, expected)
+	return nil
+}
+
+var constraintOperatorSpace = regexp.MustCompile(`([<>=~^]+)\s+`)
+
+// VersionInRange reports whether version satisfies every constraint in
+// versionRange. Constraints are separated by spaces or commas, e.g.
+// ">=1.2.0 <2.0.0" or ">= 1.2, < 2". Supported operators are =, <, <=, >,
+// >=, ~ (~1.2.3 means >=1.2.3 <1.3.0) and ^ (^1.2.3 means >=1.2.3 <2.0.0,
+// ^0.2.3 means >=0.2.3 <0.3.0). Wildcards and || are not supported.
+func VersionInRange(version, versionRange string) (bool, error) {
+	versionRange = constraintOperatorSpace.ReplaceAllString(strings.ReplaceAll(versionRange, ",", " "), "$1")
+	for _, constraint := range strings.Fields(versionRange) {
+		op := constraint[:len(constraint)-len(strings.TrimLeft(constraint, "<>=~^"))]
+		target := strings.TrimPrefix(constraint, op)
+
+		cmp, err := CompareVersions(version, target)
+		if err != nil {
+			return false, err
+		}
+
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=", "":
+			ok = cmp == 0
+		case "~", "^":
+			upper, err := constraintUpperBound(op, target)
+			if err != nil {
+				return false, err
+			}
+			below, _ := CompareVersions(version, upper)
+			ok = cmp >= 0 && below < 0
+		default:
+			return false, fmt.Errorf("invalid version constraint %s", constraint)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// constraintUpperBound returns the exclusive upper bound of a ~ or ^ constraint.
+func constraintUpperBound(op, target string) (string, error) {
+	v, err := parseVersion(target)
+	if err != nil {
+		return "", err
+	}
+	major, minor, patch := v.numbers[0], v.numbers[1], v.numbers[2]
+	switch {
+	case v.parts == 1, op == "^" && major > 0:
+		return fmt.Sprintf("%d.0.0", major+1), nil
+	case op == "~", op == "^" && (minor > 0 || v.parts < 3):
+		return fmt.Sprintf("%d.%d.0", major, minor+1), nil
+	}
+	return fmt.Sprintf("%d.%d.%d", major, minor, patch+1), nil
+}
+
+type semanticVersion struct {
+	numbers    [3]int
+	parts      int
+	prerelease []string
+}
+
+func parseVersion(v string) (semanticVersion, error) {
+	var parsed semanticVersion
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		parsed.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return parsed, fmt.Errorf("invalid version %s", v)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid version %s", v)
+		}
+		parsed.numbers[i] = n
+	}
+	parsed.parts = len(fields)
+	return parsed, nil
+}
+
+// CompareVersions compares two semantic versions, including pre-release
+// ordering (1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-beta < 1.0.0). Missing minor
+// or patch numbers count as 0 and build metadata is ignored.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.numbers {
+		if va.numbers[i] != vb.numbers[i] {
+			return compareInts(va.numbers[i], vb.numbers[i]), nil
+		}
+	}
+
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0, nil
+	case len(va.prerelease) == 0:
+		return 1, nil
+	case len(vb.prerelease) == 0:
+		return -1, nil
+	}
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if c := comparePrerelease(va.prerelease[i], vb.prerelease[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareInts(len(va.prerelease), len(vb.prerelease)), nil
+}
+
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package common
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3+build.5", "1.2.3", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0-rc1", "2.0.0", -1},
+		{"2.0.0", "2.0.0-rc1", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "abc", "1.2.3.4", "1.x"} {
+		if _, err := CompareVersions(invalid, "1.0.0"); err == nil {
+			t.Errorf("CompareVersions(%q) did not fail", invalid)
+		}
+	}
+}
+
+func TestVersionInRange(t *testing.T) {
+	tests := []struct {
+		version, versionRange string
+		want                  bool
+		wantErr               bool
+	}{
+		{version: "1.5.0", versionRange: ">=1.2.0 <2.0.0", want: true},
+		{version: "1.5.0", versionRange: ">= 1.2.0, < 2.0.0", want: true},
+		{version: "2.0.0", versionRange: ">=1.2.0 <2.0.0", want: false},
+		{version: "2.0.0-rc1", versionRange: "<2.0.0", want: true},
+		{version: "1.2.3", versionRange: "1.2.3", want: true},
+		{version: "1.2.3", versionRange: "=1.2.4", want: false},
+		{version: "1.2.9", versionRange: "~1.2.3", want: true},
+		{version: "1.3.0", versionRange: "~1.2.3", want: false},
+		{version: "1.9.0", versionRange: "~1", want: true},
+		{version: "1.9.0", versionRange: "^1.2.3", want: true},
+		{version: "2.0.0", versionRange: "^1.2.3", want: false},
+		{version: "1.2.2", versionRange: "^1.2.3", want: false},
+		{version: "0.2.9", versionRange: "^0.2.3", want: true},
+		{version: "0.3.0", versionRange: "^0.2.3", want: false},
+		{version: "0.0.4", versionRange: "^0.0.3", want: false},
+		{version: "0.9.0", versionRange: "^0", want: true},
+		{version: "1.0.0", versionRange: "!=1.0.0", wantErr: true},
+		{version: "1.0.0", versionRange: "1.x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := VersionInRange(tt.version, tt.versionRange)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("VersionInRange(%q, %q) did not fail", tt.version, tt.versionRange)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("VersionInRange(%q, %q) = %t, %v, want %t", tt.version, tt.versionRange, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLAssertion checks elements matched by a simple CSS-style selector such
+// as "title", "script[src]" or `meta[name="viewport"]`. The assertion passes
+// if any matched element's attribute (or text, when Attr is empty) satisfies
+// Equals and Matches. With neither set, the element only has to be present.
+type HTMLAssertion struct {
+	Selector string
+	Attr     string
+	Equals   string
+	Matches  string
+}
+
+func (a HTMLAssertion) String() string {
+	target := a.Selector
+	if a.Attr != "" {
+		target += " @" + a.Attr
+	}
+	switch {
+	case a.Equals != "":
+		return fmt.Sprintf("%s equals %q", target, a.Equals)
+	case a.Matches != "":
+		return fmt.Sprintf("%s matches /%s/", target, a.Matches)
+	}
+	return target + " is present"
+}
+
+type selector struct {
+	tag   string
+	attrs map[string]*string
+}
+
+func parseSelector(s string) (selector, error) {
+	sel := selector{attrs: map[string]*string{}}
+	i := strings.Index(s, "[")
+	if i < 0 {
+		sel.tag = strings.TrimSpace(s)
+		return sel, nil
+	}
+	sel.tag = strings.TrimSpace(s[:i])
+
+	for rest := s[i:]; rest != ""; {
+		end := strings.Index(rest, "]")
+		if rest[0] != '[' || end < 0 {
+			return sel, fmt.Errorf("invalid selector %s", s)
+		}
+		key, value, hasValue := strings.Cut(rest[1:end], "=")
+		if hasValue {
+			value = strings.Trim(value, `"'`)
+			sel.attrs[strings.TrimSpace(key)] = &value
+		} else {
+			sel.attrs[strings.TrimSpace(key)] = nil
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	return sel, nil
+}
+
+func (sel selector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode || (sel.tag != "" && sel.tag != "*" && n.Data != sel.tag) {
+		return false
+	}
+	for key, want := range sel.attrs {
+		value, ok := nodeAttr(n, key)
+		if !ok || (want != nil && !strings.EqualFold(value, *want)) {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeAttr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+func FindElements(doc *html.Node, s string) ([]*html.Node, error) {
+	sel, err := parseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if sel.match(n) {
+			found = append(found, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found, nil
+}
+
+func FetchHTML(pageURL string) (*html.Node, error) {
+	resp, err := http.Get(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML from %s: %v", pageURL, err)
+	}
+	return doc, nil
+}
+
+func CheckHTMLAssertions(doc *html.Node, assertions []HTMLAssertion) error {
+	for _, assertion := range assertions {
+		elements, err := FindElements(doc, assertion.Selector)
+		if err != nil {
+			return err
+		}
+
+		var re *regexp.Regexp
+		if assertion.Matches != "" {
+			if re, err = regexp.Compile(assertion.Matches); err != nil {
+				return fmt.Errorf("invalid pattern in assertion %s: %v", assertion, err)
+			}
+		}
+
+		var seen []string
+		passed := false
+		for _, n := range elements {
+			value := nodeText(n)
+			if assertion.Attr != "" {
+				value, _ = nodeAttr(n, assertion.Attr)
+			}
+			seen = append(seen, value)
+			if (assertion.Equals == "" || value == assertion.Equals) && (re == nil || re.MatchString(value)) {
+				passed = true
+				break
+			}
+		}
+		if !passed {
+			if len(elements) == 0 {
+				return fmt.Errorf("assertion failed: %s (no %s element found)", assertion, assertion.Selector)
+			}
+			return fmt.Errorf("assertion failed: %s (found %q)", assertion, seen)
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"%s.\n", assertion)
+	}
+	return nil
+}
+
+type pageAsset struct {
+	url         string
+	contentType string
+}
+
+func discoverAssets(doc *html.Node, base *url.URL) ([]pageAsset, error) {
+	var assets []pageAsset
+	add := func(selector, attr, contentType string) error {
+		elements, err := FindElements(doc, selector)
+		if err != nil {
+			return err
+		}
+		for _, n := range elements {
+			ref, _ := nodeAttr(n, attr)
+			u, err := base.Parse(ref)
+			if err != nil {
+				return fmt.Errorf("invalid asset URL %s: %v", ref, err)
+			}
+			assets = append(assets, pageAsset{url: u.String(), contentType: contentType})
+		}
+		return nil
+	}
+
+	if err := add("script[src]", "src", "javascript"); err != nil {
+		return nil, err
+	}
+	if err := add("link[rel=stylesheet][href]", "href", "text/css"); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func CheckPageAssets(pageURL string, doc *html.Node) error {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("invalid page URL %s: %v", pageURL, err)
+	}
+	assets, err := discoverAssets(doc, base)
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		resp, err := http.Get(asset.url)
+		if err != nil {
+			return fmt.Errorf("failed to fetch asset %s: %v", asset.url, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("asset %s returns %d", asset.url, resp.StatusCode)
+		}
+		if contentType := resp.Header.Get("Content-Type"); !strings.Contains(contentType, asset.contentType) {
+			return fmt.Errorf("asset %s has content type %q, expected %s", asset.url, contentType, asset.contentType)
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"Asset %s returns %d.\n", asset.url, http.StatusOK)
+	}
+	return nil
+}
+
+func CheckWebPage(pageURL string, assertions []HTMLAssertion) error {
+	doc, err := FetchHTML(pageURL)
+	if err != nil {
+		return err
+	}
+	if err := CheckHTMLAssertions(doc, assertions); err != nil {
+		return err
+	}
+	return CheckPageAssets(pageURL, doc)
+}
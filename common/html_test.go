@@ -0,0 +1,58 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const selectorTestPage = `<html><head>
+<title>Uber To Do</title>
+<meta name="viewport" content="width=device-width">
+<meta charset="utf-8">
+<script src="/static/js/main.1a2b3c.js"></script>
+<script>window.env = {}</script>
+<link rel="stylesheet" href="/static/css/main.css">
+</head><body><div id="root" data-api="http://localhost:8000"></div></body></html>`
+
+func TestFindElements(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(selectorTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		selector string
+		want     int
+		wantErr  bool
+	}{
+		{selector: "title", want: 1},
+		{selector: " script ", want: 2},
+		{selector: "script[src]", want: 1},
+		{selector: "meta[name=viewport]", want: 1},
+		{selector: `meta[name="VIEWPORT"]`, want: 1},
+		{selector: `meta[name='viewport']`, want: 1},
+		{selector: "meta[name=description]", want: 0},
+		{selector: `link[rel=stylesheet][href="/static/css/main.css"]`, want: 1},
+		{selector: "[data-api]", want: 1},
+		{selector: "*[id=root]", want: 1},
+		{selector: "div", want: 1},
+		{selector: "span", want: 0},
+		{selector: "script[src", wantErr: true},
+		{selector: "script[src]defer", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			found, err := FindElements(doc, tt.selector)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("FindElements(%s) found %d elements, want an error", tt.selector, len(found))
+				}
+				return
+			}
+			if err != nil || len(found) != tt.want {
+				t.Errorf("FindElements(%s) found %d elements, %v, want %d", tt.selector, len(found), err, tt.want)
+			}
+		})
+	}
+}
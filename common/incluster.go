@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	probeBusyboxImage = "busybox:1.36"
+	probeCurlImage    = "curlimages/curl:8.8.0"
+	probeTimeout      = 90 * time.Second
+	probeStatusMarker = "grader-status:"
+)
+
+func RunProbePod(namespace, image string, command ...string) (string, error) {
+	podName := fmt.Sprintf("grader-probe-%d", time.Now().UnixNano())
+	defer DeleteProbePod(namespace, podName)
+
+	args := []string{
+		"run", podName,
+		"-n", namespace,
+		"--image=" + image,
+		"--restart=Never",
+		"--rm", "-i", "--quiet",
+		"--labels=app.kubernetes.io/managed-by=sds-grader",
+		"--pod-running-timeout=" + probeTimeout.String(),
+		"--command", "--",
+	}
+	args = append(args, command...)
+
+	output, err := RunCommandWithTimeout(probeTimeout, "kubectl", args...)
+	if err != nil {
+		return string(output), fmt.Errorf("probe pod %s failed: %v", podName, err)
+	}
+	return string(output), nil
+}
+
+func DeleteProbePod(namespace, podName string) {
+	cmd := exec.Command("kubectl", "delete", "pod", podName, "-n", namespace, "--ignore-not-found", "--wait=false")
+	_ = cmd.Run()
+}
+
+func CheckInClusterDNS(namespace, host string) error {
+	output, err := RunProbePod(namespace, probeBusyboxImage, "nslookup", host)
+	if err != nil {
+		return fmt.Errorf("host %s cannot be resolved inside namespace %s: %v", host, namespace, err)
+	}
+	if strings.Contains(output, "can't find") || strings.Contains(output, "NXDOMAIN") {
+		return fmt.Errorf("host %s cannot be resolved inside namespace %s", host, namespace)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Host %s resolves inside namespace %s.\n", host, namespace)
+	return nil
+}
+
+func CheckInClusterTCP(namespace, host string, port int) error {
+	address := host + " " + strconv.Itoa(port)
+	if _, err := RunProbePod(namespace, probeBusyboxImage, "sh", "-c", "nc -z -w 5 "+address); err != nil {
+		return fmt.Errorf("%s:%d is not reachable inside namespace %s: %v", host, port, namespace, err)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"%s:%d is reachable inside namespace %s.\n", host, port, namespace)
+	return nil
+}
+
+// parseProbeStatus finds the status printed by curl after probeStatusMarker,
+// ignoring anything else kubectl or the pod wrote around it.
+func parseProbeStatus(output string) (int, error) {
+	for _, line := range strings.Split(output, "\n") {
+		if i := strings.Index(line, probeStatusMarker); i >= 0 {
+			return strconv.Atoi(strings.TrimSpace(line[i+len(probeStatusMarker):]))
+		}
+	}
+	return 0, fmt.Errorf("no status in probe output")
+}
+
+func CheckInClusterHTTPStatus(namespace, url string, expectedStatus int) error {
+	output, err := RunProbePod(namespace, probeCurlImage, "curl", "-s", "-o", "/dev/null", "-m", "10", "-w", probeStatusMarker+"%{http_code}\n", url)
+	if err != nil {
+		return fmt.Errorf("URL %s is not reachable inside namespace %s: %v", url, namespace, err)
+	}
+
+	status, err := parseProbeStatus(output)
+	if err != nil {
+		return fmt.Errorf("unexpected probe output for %s: %q", url, output)
+	}
+	if status != expectedStatus {
+		return fmt.Errorf("URL %s inside namespace %s returns %d, expected %d", url, namespace, status, expectedStatus)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"URL %s returns %d inside namespace %s.\n", url, expectedStatus, namespace)
+	return nil
+}
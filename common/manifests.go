@@ -0,0 +1,405 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var expectedAPIVersions = map[string]string{
+	"Namespace":               "v1",
+	"Service":                 "v1",
+	"ConfigMap":               "v1",
+	"Secret":                  "v1",
+	"PersistentVolumeClaim":   "v1",
+	"Deployment":              "apps/v1",
+	"StatefulSet":             "apps/v1",
+	"Ingress":                 "networking.k8s.io/v1",
+	"HorizontalPodAutoscaler": "autoscaling/v2",
+}
+
+type KubernetesManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec yaml.Node `yaml:"spec"`
+
+	File string `yaml:"-"`
+	Line int    `yaml:"-"`
+}
+
+func (m KubernetesManifest) location() string {
+	return fmt.Sprintf("%s:%d %s/%s", m.File, m.Line, m.Kind, m.Metadata.Name)
+}
+
+type k8sContainer struct {
+	Name      string `yaml:"name"`
+	Image     string `yaml:"image"`
+	Resources struct {
+		Requests map[string]string `yaml:"requests"`
+		Limits   map[string]string `yaml:"limits"`
+	} `yaml:"resources"`
+	ReadinessProbe *yaml.Node `yaml:"readinessProbe"`
+	LivenessProbe  *yaml.Node `yaml:"livenessProbe"`
+}
+
+type k8sDeploymentSpec struct {
+	Replicas *int `yaml:"replicas"`
+	Selector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	} `yaml:"selector"`
+	Template struct {
+		Metadata struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Containers []k8sContainer `yaml:"containers"`
+		} `yaml:"spec"`
+	} `yaml:"template"`
+}
+
+type k8sServicePort struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+type k8sServiceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+	Ports    []k8sServicePort  `yaml:"ports"`
+}
+
+type k8sIngressBackend struct {
+	Service *struct {
+		Name string `yaml:"name"`
+		Port struct {
+			Name   string `yaml:"name"`
+			Number int    `yaml:"number"`
+		} `yaml:"port"`
+	} `yaml:"service"`
+}
+
+type k8sIngressSpec struct {
+	DefaultBackend *k8sIngressBackend `yaml:"defaultBackend"`
+	Rules          []struct {
+		Host string `yaml:"host"`
+		HTTP *struct {
+			Paths []struct {
+				Path    string            `yaml:"path"`
+				Backend k8sIngressBackend `yaml:"backend"`
+			} `yaml:"paths"`
+		} `yaml:"http"`
+	} `yaml:"rules"`
+}
+
+type KubernetesExpectation struct {
+	Name        string
+	ServicePort int
+}
+
+var TodoKubernetesExpectation = KubernetesExpectation{Name: "todo", ServicePort: 80}
+
+func LoadKubernetesManifests(dir string) ([]KubernetesManifest, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest directory %s: %v", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no YAML manifests found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var manifests []KubernetesManifest
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", file, err)
+		}
+		decoder := yaml.NewDecoder(f)
+		for {
+			var node yaml.Node
+			err := decoder.Decode(&node)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+			}
+			if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+				continue
+			}
+			var manifest KubernetesManifest
+			if err := node.Decode(&manifest); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse %s:%d: %v", file, node.Line, err)
+			}
+			manifest.File = file
+			manifest.Line = node.Content[0].Line
+			manifests = append(manifests, manifest)
+		}
+		f.Close()
+	}
+	return manifests, nil
+}
+
+func LintKubernetesManifests(dir string, expectation KubernetesExpectation) []bool {
+	manifests, err := LoadKubernetesManifests(dir)
+	if !CheckResult(err, fmt.Sprintf("Kubernetes manifests in %s can be parsed.", dir)) {
+		return []bool{false}
+	}
+
+	return []bool{
+		CheckResult(CheckManifestAPIVersions(manifests), "All manifests have a valid apiVersion and kind."),
+		CheckResult(CheckManifestExpectation(manifests, expectation), "Deployment, Service and Ingress for "+expectation.Name+" are declared."),
+		CheckResult(CheckManifestSelectors(manifests), "Deployment and Service labels and selectors are consistent."),
+		CheckResult(CheckManifestIngressBackends(manifests), "Ingress backends reference existing services and ports."),
+		CheckResult(CheckManifestResources(manifests), "All containers have resource requests and limits."),
+		CheckResult(CheckManifestProbes(manifests), "All containers have readiness and liveness probes."),
+	}
+}
+
+func manifestsOfKind(manifests []KubernetesManifest, kind string) []KubernetesManifest {
+	var result []KubernetesManifest
+	for _, m := range manifests {
+		if m.Kind == kind {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func decodeSpec(m KubernetesManifest, spec interface{}) error {
+	if m.Spec.Kind == 0 {
+		return fmt.Errorf("%s has no spec", m.location())
+	}
+	if err := m.Spec.Decode(spec); err != nil {
+		return fmt.Errorf("%s has an invalid spec: %v", m.location(), err)
+	}
+	return nil
+}
+
+func labelsMatch(selector, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func CheckManifestAPIVersions(manifests []KubernetesManifest) error {
+	var errs []error
+	for _, m := range manifests {
+		if m.APIVersion == "" || m.Kind == "" {
+			errs = append(errs, fmt.Errorf("%s:%d is missing apiVersion or kind", m.File, m.Line))
+			continue
+		}
+		if m.Metadata.Name == "" {
+			errs = append(errs, fmt.Errorf("%s is missing metadata.name", m.location()))
+		}
+		if expected, ok := expectedAPIVersions[m.Kind]; ok && m.APIVersion != expected {
+			errs = append(errs, fmt.Errorf("%s uses apiVersion %s, expected %s", m.location(), m.APIVersion, expected))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckManifestExpectation(manifests []KubernetesManifest, expectation KubernetesExpectation) error {
+	var errs []error
+
+	found := false
+	for _, m := range manifestsOfKind(manifests, "Deployment") {
+		if m.Metadata.Name == expectation.Name {
+			found = true
+		}
+	}
+	if !found {
+		errs = append(errs, fmt.Errorf("Deployment %s is not declared", expectation.Name))
+	}
+
+	found = false
+	for _, m := range manifestsOfKind(manifests, "Service") {
+		if m.Metadata.Name != expectation.Name {
+			continue
+		}
+		found = true
+		var spec k8sServiceSpec
+		if err := decodeSpec(m, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		hasPort := false
+		for _, port := range spec.Ports {
+			if port.Port == expectation.ServicePort {
+				hasPort = true
+			}
+		}
+		if !hasPort {
+			errs = append(errs, fmt.Errorf("%s does not expose port %d", m.location(), expectation.ServicePort))
+		}
+	}
+	if !found {
+		errs = append(errs, fmt.Errorf("Service %s is not declared", expectation.Name))
+	}
+
+	if len(manifestsOfKind(manifests, "Ingress")) == 0 {
+		errs = append(errs, fmt.Errorf("no Ingress is declared"))
+	}
+	return errors.Join(errs...)
+}
+
+func CheckManifestSelectors(manifests []KubernetesManifest) error {
+	var errs []error
+	var podLabels []map[string]string
+
+	for _, m := range manifestsOfKind(manifests, "Deployment") {
+		var spec k8sDeploymentSpec
+		if err := decodeSpec(m, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !labelsMatch(spec.Selector.MatchLabels, spec.Template.Metadata.Labels) {
+			errs = append(errs, fmt.Errorf("%s selector %v does not match its pod template labels %v", m.location(), spec.Selector.MatchLabels, spec.Template.Metadata.Labels))
+		}
+		podLabels = append(podLabels, spec.Template.Metadata.Labels)
+	}
+
+	for _, m := range manifestsOfKind(manifests, "Service") {
+		var spec k8sServiceSpec
+		if err := decodeSpec(m, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		matched := false
+		for _, labels := range podLabels {
+			if labelsMatch(spec.Selector, labels) {
+				matched = true
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("%s selector %v does not match any Deployment pod labels", m.location(), spec.Selector))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckManifestIngressBackends(manifests []KubernetesManifest) error {
+	var errs []error
+	services := map[string]k8sServiceSpec{}
+	for _, m := range manifestsOfKind(manifests, "Service") {
+		var spec k8sServiceSpec
+		if err := decodeSpec(m, &spec); err == nil {
+			services[m.Metadata.Name] = spec
+		}
+	}
+
+	checkBackend := func(m KubernetesManifest, backend k8sIngressBackend) {
+		if backend.Service == nil {
+			errs = append(errs, fmt.Errorf("%s has a backend without a service", m.location()))
+			return
+		}
+		spec, ok := services[backend.Service.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s references unknown service %s", m.location(), backend.Service.Name))
+			return
+		}
+		for _, port := range spec.Ports {
+			if (backend.Service.Port.Number != 0 && port.Port == backend.Service.Port.Number) ||
+				(backend.Service.Port.Name != "" && port.Name == backend.Service.Port.Name) {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s references port %d%s which is not exposed by service %s", m.location(), backend.Service.Port.Number, backend.Service.Port.Name, backend.Service.Name))
+	}
+
+	for _, m := range manifestsOfKind(manifests, "Ingress") {
+		var spec k8sIngressSpec
+		if err := decodeSpec(m, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if spec.DefaultBackend != nil {
+			checkBackend(m, *spec.DefaultBackend)
+		}
+		for _, rule := range spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				checkBackend(m, path.Backend)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type deploymentContainer struct {
+	location string
+	k8sContainer
+}
+
+func deploymentContainers(manifests []KubernetesManifest) ([]deploymentContainer, error) {
+	var errs []error
+	var containers []deploymentContainer
+	for _, m := range manifestsOfKind(manifests, "Deployment") {
+		var spec k8sDeploymentSpec
+		if err := decodeSpec(m, &spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, c := range spec.Template.Spec.Containers {
+			containers = append(containers, deploymentContainer{location: m.location(), k8sContainer: c})
+		}
+	}
+	return containers, errors.Join(errs...)
+}
+
+func CheckManifestResources(manifests []KubernetesManifest) error {
+	containers, err := deploymentContainers(manifests)
+	errs := []error{err}
+	for _, c := range containers {
+		if len(c.Resources.Requests) == 0 {
+			errs = append(errs, fmt.Errorf("%s container %s has no resource requests", c.location, c.Name))
+		}
+		if len(c.Resources.Limits) == 0 {
+			errs = append(errs, fmt.Errorf("%s container %s has no resource limits", c.location, c.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CheckManifestProbes(manifests []KubernetesManifest) error {
+	containers, err := deploymentContainers(manifests)
+	errs := []error{err}
+	for _, c := range containers {
+		if c.ReadinessProbe == nil {
+			errs = append(errs, fmt.Errorf("%s container %s has no readinessProbe", c.location, c.Name))
+		}
+		if c.LivenessProbe == nil {
+			errs = append(errs, fmt.Errorf("%s container %s has no livenessProbe", c.location, c.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
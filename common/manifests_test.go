@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadKubernetesManifests(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "multiple documents",
+			files: map[string]string{"todo.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: todo
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: todo
+`},
+			want: []string{"todo.yaml:1 Deployment/todo", "todo.yaml:6 Service/todo"},
+		},
+		{
+			name: "leading separator, empty and comment only documents",
+			files: map[string]string{"redis.yml": `---
+# redis
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: redis
+---
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: redis
+...
+`},
+			want: []string{"redis.yml:4 StatefulSet/redis", "redis.yml:10 Service/redis"},
+		},
+		{
+			name: "files in sorted order including subdirectories",
+			files: map[string]string{
+				"b.yaml":           "kind: Service\nmetadata:\n  name: b\n",
+				"a/ingress.yaml":   "kind: Ingress\nmetadata:\n  name: a\n",
+				"notes.txt":        "kind: Secret\n",
+				"a/kustomize.json": "{}",
+			},
+			want: []string{"a/ingress.yaml:1 Ingress/a", "b.yaml:1 Service/b"},
+		},
+		{
+			name:    "invalid second document",
+			files:   map[string]string{"todo.yaml": "kind: Service\nmetadata:\n  name: todo\n---\nkind: [\n"},
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "no yaml files",
+			files:   map[string]string{"README.md": "# manifests"},
+			wantErr: "no YAML manifests found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manifests, err := LoadKubernetesManifests(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadKubernetesManifests() = %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range manifests {
+				rel, _ := filepath.Rel(dir, m.File)
+				got = append(got, fmt.Sprintf("%s:%d %s/%s", filepath.ToSlash(rel), m.Line, m.Kind, m.Metadata.Name))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadKubernetesManifests() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// OutboxEntry is a passing result that could not be submitted. Payload holds
+// the message exactly as HandleSuccess built and signed it. Entries are
+// stored one per line, encrypted with the grader's key.
+type OutboxEntry struct {
+	Backend string          `json:"backend"`
+	Error   string          `json:"error"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// String describes the queued result for the user.
+func (e OutboxEntry) String() string {
+	var m Message
+	if err := json.Unmarshal(e.Payload, &m); err != nil {
+		return "unreadable result"
+	}
+	return fmt.Sprintf("%s (%d %s)", m.Field1.Format("2006-01-02 15:04:05"), m.Field2, m.Field3)
+}
+
+// OutboxPath returns the outbox file of the grader with the given key, so
+// that every activity keeps its own queue. SDS_GRADER_OUTBOX overrides it.
+func OutboxPath(key []byte) (string, error) {
+	if path := os.Getenv("SDS_GRADER_OUTBOX"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %v", err)
+	}
+	sum := sha256.Sum256(key)
+	return filepath.Join(dir, "sds-grader", "outbox-"+hex.EncodeToString(sum[:4])+".enc"), nil
+}
+
+func SaveToOutbox(key []byte, entry OutboxEntry) (string, error) {
+	path, err := OutboxPath(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal outbox entry: %v", err)
+	}
+	line, err := EncryptJSON(key, data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, nil
+}
+
+func LoadOutbox(key []byte) ([]OutboxEntry, error) {
+	path, err := OutboxPath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	// Lines that cannot be read are reported and skipped, so one corrupted
+	// line does not block the rest of the queue.
+	var entries []OutboxEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry OutboxEntry
+		plaintext, err := DecryptJSON(key, line)
+		if err == nil {
+			err = json.Unmarshal(plaintext, &entry)
+		}
+		if err != nil {
+			log.Printf("%sSkipping line %d of %s: %v\n", ErrorPrefix, number, path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// WriteOutbox replaces the outbox with entries, removing it when empty.
+func WriteOutbox(key []byte, entries []OutboxEntry) error {
+	path, err := OutboxPath(key)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox entry: %v", err)
+		}
+		line, err := EncryptJSON(key, data)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmp, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func submitData(ctx context.Context, backend string, encryptedServiceAccountJSON []byte, key []byte, project string, topic string, data []byte) error {
+	submitter, err := NewSubmitter(ctx, backend, encryptedServiceAccountJSON, key, project, topic)
+	if err != nil {
+		return err
+	}
+	defer submitter.Close()
+	return submitter.Submit(ctx, data)
+}
+
+// submitOrQueue submits message and, if that fails for a reason that may go
+// away, e.g. no network, queues a copy marked as delayed. The copy is signed
+// here, so a retry only resends its bytes. Other errors are returned.
+func submitOrQueue(ctx context.Context, encryptedServiceAccountJSON []byte, key []byte, project string, topic string, message Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	submitErr := submitData(ctx, SubmitBackend, encryptedServiceAccountJSON, key, project, topic, data)
+	if submitErr == nil || !IsTransientError(submitErr) {
+		return submitErr
+	}
+	log.Printf("%sFailed to submit: %v\n", ErrorPrefix, submitErr)
+
+	message.Field12 = true
+	if err := SignMessage(&message, key); err != nil {
+		return err
+	}
+	if SigningKey == "" {
+		log.Println("⚠️ This grader was built without a signing key, so the queued result is not signed and may not be accepted as proof of a passing run.")
+	}
+	delayed, _ := json.Marshal(message)
+	path, err := SaveToOutbox(key, OutboxEntry{Backend: SubmitBackend, Error: submitErr.Error(), Payload: delayed})
+	if err != nil {
+		return fmt.Errorf("%v. Saving the result to the outbox also failed: %v", submitErr, err)
+	}
+	log.Printf("📮 Your result was saved to %s.\n", path)
+	log.Printf("📮 Run '%s submit --retry' when you are back online to send it.\n", filepath.Base(os.Args[0]))
+	return nil
+}
+
+// RetryOutbox resends every queued result exactly as it was queued, without
+// changing or re-signing it. Results that fail again stay in the outbox.
+func RetryOutbox(ctx context.Context, encryptedServiceAccountJSON []byte, key []byte, project string, topic string) error {
+	entries, err := LoadOutbox(key)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		log.Println("No pending submissions.")
+		return nil
+	}
+
+	// Builds without a signing key queue unsigned results, which are resent
+	// as they are. Signed builds only resend results they signed.
+	var public ed25519.PublicKey
+	if SigningKey != "" {
+		if public, err = SigningPublicKey(key); err != nil {
+			return err
+		}
+	}
+
+	var remaining []OutboxEntry
+	for _, entry := range entries {
+		// Anything that does not verify was not queued by HandleSuccess and
+		// is dropped.
+		message, err := ParseSubmission(entry.Payload)
+		if err == nil && public != nil {
+			err = VerifyMessage(message, []ed25519.PublicKey{public})
+		}
+		if err != nil {
+			log.Printf("%sDropping result from %s: %v\n", ErrorPrefix, entry, err)
+			continue
+		}
+		if err := submitData(ctx, entry.Backend, encryptedServiceAccountJSON, key, project, topic, entry.Payload); err != nil {
+			log.Printf("%sFailed to submit result from %s: %v\n", ErrorPrefix, entry, err)
+			entry.Error = err.Error()
+			remaining = append(remaining, entry)
+			continue
+		}
+		log.Printf(SuccessPrefix+"Submitted result from %s.\n", entry)
+	}
+
+	if err := WriteOutbox(key, remaining); err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("%d of %d submissions are still pending", len(remaining), len(entries))
+	}
+	return nil
+}
+
+// RunSubmitCommand handles '<grader> submit [--retry]'. Without --retry it
+// lists the pending submissions.
+func RunSubmitCommand(args []string, encryptedServiceAccountJSON []byte, key []byte, project string, topic string) {
+	flags := flag.NewFlagSet("submit", flag.ExitOnError)
+	retry := flags.Bool("retry", false, "resubmit results saved in the outbox")
+	flags.Parse(args)
+
+	if *retry {
+		HandleError(RetryOutbox(context.Background(), encryptedServiceAccountJSON, key, project, topic), "Retry failed")
+		return
+	}
+
+	entries, err := LoadOutbox(key)
+	HandleError(err, "Failed to read outbox")
+	log.Printf("%d pending submission(s).\n", len(entries))
+	for _, entry := range entries {
+		log.Printf(SpacePrefix+"%s: %s\n", entry, entry.Error)
+	}
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSubmitOrQueue(t *testing.T) {
+	key := []byte("http://localhosthttp://localhost")
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	offline := httptest.NewServer(http.NotFoundHandler())
+	offline.Close()
+
+	tests := []struct {
+		name      string
+		backend   string
+		wantErr   bool
+		wantQueue int
+	}{
+		{name: "written", backend: "file:" + filepath.Join(t.TempDir(), "submissions.jsonl")},
+		{name: "missing file directory", backend: "file:" + filepath.Join(t.TempDir(), "missing", "submissions.jsonl"), wantErr: true},
+		{name: "unknown backend", backend: "carrier-pigeon", wantErr: true},
+		{name: "webhook rejects", backend: "webhook:" + unauthorized.URL, wantErr: true},
+		{name: "webhook unavailable", backend: "webhook:" + unavailable.URL, wantQueue: 1},
+		{name: "offline", backend: "webhook:" + offline.URL, wantQueue: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SDS_GRADER_OUTBOX", filepath.Join(t.TempDir(), "outbox.enc"))
+			previous := SubmitBackend
+			SubmitBackend = tt.backend
+			defer func() { SubmitBackend = previous }()
+
+			message := CreateMessage(time.Now(), 6612345, "name", "host", "user", "linux", "24.04", 3600, "10.0.0.2", "1.2.3.4", "")
+			err := submitOrQueue(context.Background(), nil, key, "project", "topic", message)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("submitOrQueue() = %v, want error %v", err, tt.wantErr)
+			}
+			entries, err := LoadOutbox(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.wantQueue {
+				t.Fatalf("outbox has %d entries, want %d", len(entries), tt.wantQueue)
+			}
+			for _, entry := range entries {
+				queued, err := ParseSubmission(entry.Payload)
+				if err != nil || !queued.Field12 || queued.Field15 != "" {
+					t.Errorf("queued %s, %v, want an unsigned delayed message", entry.Payload, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisTimeout = 5 * time.Second
+
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// RedisClient is a minimal RESP client, just enough to grade redis setups
+// without depending on redis-cli being installed.
+type RedisClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func DialRedis(address string) (*RedisClient, error) {
+	conn, err := net.DialTimeout("tcp", address, redisTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %v", address, err)
+	}
+	return &RedisClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *RedisClient) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a command and returns its reply as a string, int64, []interface{}
+// or nil. Error replies are returned as RedisError.
+func (c *RedisClient) Do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("failed to send redis command: %v", err)
+	}
+	return c.readReply()
+}
+
+func (c *RedisClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read redis reply: %v", err)
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+func (c *RedisClient) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, fmt.Errorf("failed to read redis reply: %v", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown redis reply: %q", line)
+}
+
+func (c *RedisClient) Ping() error {
+	reply, err := c.Do("PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected PING reply: %v", reply)
+	}
+	return nil
+}
+
+func (c *RedisClient) Auth(password string) error {
+	_, err := c.Do("AUTH", password)
+	return err
+}
+
+func (c *RedisClient) Get(key string) (string, error) {
+	reply, err := c.Do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	value, _ := reply.(string)
+	return value, nil
+}
+
+func (c *RedisClient) Set(key, value string) error {
+	_, err := c.Do("SET", key, value)
+	return err
+}
+
+// Values returns all values stored under key, whatever its type.
+func (c *RedisClient) Values(key string) ([]string, error) {
+	reply, err := c.Do("TYPE", key)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	switch reply {
+	case "string":
+		args = []string{"GET", key}
+	case "hash":
+		args = []string{"HGETALL", key}
+	case "list":
+		args = []string{"LRANGE", key, "0", "-1"}
+	case "set":
+		args = []string{"SMEMBERS", key}
+	case "zset":
+		args = []string{"ZRANGE", key, "0", "-1"}
+	default:
+		return nil, nil
+	}
+
+	reply, err = c.Do(args...)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := reply.(string); ok {
+		return []string{value}, nil
+	}
+	var values []string
+	items, _ := reply.([]interface{})
+	for _, item := range items {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+func (c *RedisClient) FindValue(word string, maxKeys int) (string, error) {
+	cursor := "0"
+	scanned := 0
+	for {
+		reply, err := c.Do("SCAN", cursor, "COUNT", "100")
+		if err != nil {
+			return "", err
+		}
+		items, _ := reply.([]interface{})
+		if len(items) != 2 {
+			return "", fmt.Errorf("unexpected SCAN reply")
+		}
+		cursor, _ = items[0].(string)
+		keys, _ := items[1].([]interface{})
+
+		for _, k := range keys {
+			key, _ := k.(string)
+			values, err := c.Values(key)
+			if err != nil {
+				return "", err
+			}
+			for _, value := range values {
+				if strings.Contains(value, word) {
+					return key, nil
+				}
+			}
+			if scanned++; scanned >= maxKeys {
+				return "", fmt.Errorf("no key containing '%s' in the first %d keys", word, maxKeys)
+			}
+		}
+		if cursor == "0" {
+			return "", fmt.Errorf("no key containing '%s' found in redis", word)
+		}
+	}
+}
+
+func CheckRedisUnreachable(address string) error {
+	conn, err := net.DialTimeout("tcp", address, redisTimeout)
+	if err != nil {
+		log.Printf(SpacePrefix+SuccessPrefix+"Redis is not reachable at %s.\n", address)
+		return nil
+	}
+	conn.Close()
+	return fmt.Errorf("redis should not be reachable at %s", address)
+}
+
+// CheckRedis connects to redis at address, verifies that it asks for a
+// password when requirePassword is set, and looks for a key holding word.
+func CheckRedis(address, password string, requirePassword bool, word string) error {
+	client, err := DialRedis(address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Ping()
+	noAuth := err != nil && strings.HasPrefix(err.Error(), "NOAUTH")
+	if err != nil && !noAuth {
+		return fmt.Errorf("redis at %s does not answer PING: %v", address, err)
+	}
+	if requirePassword && !noAuth {
+		return fmt.Errorf("redis at %s does not require a password", address)
+	}
+	if noAuth {
+		if password == "" {
+			return fmt.Errorf("redis at %s requires a password", address)
+		}
+		if err := client.Auth(password); err != nil {
+			return fmt.Errorf("redis at %s rejected the password: %v", address, err)
+		}
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Redis answers PING at %s.\n", address)
+
+	if word != "" {
+		key, err := client.FindValue(word, 1000)
+		if err != nil {
+			return err
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"Redis key %s holds '%s'.\n", key, word)
+	}
+	return nil
+}
+
+// PortForward forwards a free local port to remotePort of target (e.g.
+// "svc/redis") and returns the local address and a function to stop it.
+func PortForward(namespace, target string, remotePort int) (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find a free local port: %v", err)
+	}
+	localPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cmd := exec.Command("kubectl", "port-forward", "-n", namespace, target, fmt.Sprintf("%d:%d", localPort, remotePort))
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start kubectl port-forward: %v", err)
+	}
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+
+	address := fmt.Sprintf("127.0.0.1:%d", localPort)
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if conn, err := net.DialTimeout("tcp", address, time.Second); err == nil {
+			conn.Close()
+			return address, stop, nil
+		}
+	}
+	stop()
+	return "", nil, fmt.Errorf("kubectl port-forward to %s did not become ready", target)
+}
+
+func CheckRedisInCluster(namespace, service, password string, requirePassword bool, word string) error {
+	address, stop, err := PortForward(namespace, "svc/"+service, 6379)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	return CheckRedis(address, password, requirePassword, word)
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "simple string", reply: "+PONG\r\n", want: "PONG"},
+		{name: "integer", reply: ":42\r\n", want: int64(42)},
+		{name: "bulk string", reply: "$5\r\nhello\r\n", want: "hello"},
+		{name: "bulk string with CRLF", reply: "$7\r\nhe\r\nllo\r\n", want: "he\r\nllo"},
+		{name: "empty bulk string", reply: "$0\r\n\r\n", want: ""},
+		{name: "nil bulk string", reply: "$-1\r\n", want: nil},
+		{name: "nil array", reply: "*-1\r\n", want: nil},
+		{name: "empty array", reply: "*0\r\n", want: []interface{}{}},
+		{name: "array with nil", reply: "*3\r\n$3\r\nfoo\r\n$-1\r\n:1\r\n", want: []interface{}{"foo", nil, int64(1)}},
+		{name: "nested array", reply: "*2\r\n$1\r\n0\r\n*1\r\n$4\r\ntodo\r\n", want: []interface{}{"0", []interface{}{"todo"}}},
+		{name: "error", reply: "-NOAUTH Authentication required.\r\n", wantErr: "NOAUTH Authentication required."},
+		{name: "wrong type error", reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", wantErr: "WRONGTYPE Operation against a key holding the wrong kind of value"},
+		{name: "error in array", reply: "*2\r\n+OK\r\n-ERR boom\r\n", wantErr: "ERR boom"},
+		{name: "truncated bulk string", reply: "$10\r\nhello\r\n", wantErr: "failed to read redis reply"},
+		{name: "empty reply", reply: "\r\n", wantErr: "empty redis reply"},
+		{name: "unknown type", reply: "!oops\r\n", wantErr: "unknown redis reply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &RedisClient{reader: bufio.NewReader(strings.NewReader(tt.reply))}
+			got, err := c.readReply()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("readReply(%q) = %v, %v, want error %q", tt.reply, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readReply(%q) = %#v, %v, want %#v", tt.reply, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadReplyRedisError(t *testing.T) {
+	c := &RedisClient{reader: bufio.NewReader(strings.NewReader("-NOAUTH Authentication required.\r\n"))}
+	_, err := c.readReply()
+	if _, ok := err.(RedisError); !ok {
+		t.Errorf("readReply() = %T, want RedisError", err)
+	}
+}
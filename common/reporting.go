@@ -6,10 +6,13 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"io"
 	"log"
 	"net"
@@ -22,34 +25,41 @@ import (
 	"github.com/shirou/gopsutil/host"
 )
 
+// EmulatorTopic is used when PUBSUB_EMULATOR_HOST is set and the grader was
+// built without a topic, e.g. with 'go run'.
+const EmulatorTopic = "local"
+
 type Message struct {
-	Field1  time.Time `json:"timestamp"`
-	Field2  int       `json:"id"`
-	Field3  string    `json:"name"`
-	Field4  string    `json:"host"`
-	Field5  string    `json:"user"`
-	Field6  string    `json:"os"`
-	Field7  string    `json:"version"`
-	Field8  int       `json:"uptime"`
-	Field9  string    `json:"ip"`
-	Field10 string    `json:"pub_ip"`
+	Field1  time.Time     `json:"timestamp"`
+	Field2  int           `json:"id"`
+	Field3  string        `json:"name"`
+	Field4  string        `json:"host"`
+	Field5  string        `json:"user"`
+	Field6  string        `json:"os"`
+	Field7  string        `json:"version"`
+	Field8  int           `json:"uptime"`
+	Field9  string        `json:"ip"`
+	Field10 string        `json:"pub_ip"`
+	Field11 string        `json:"cluster"`
+	Field12 bool          `json:"delayed"`
+	Field13 []CheckRecord `json:"checks"`
+	Field14 string        `json:"evidence_digest"`
+	Field15 string        `json:"signature"`
+	Field16 string        `json:"key_id"`
 }
 
-func HandleSuccess(currentTime time.Time, encryptedServiceAccountJSON []byte, key []byte, project string, topic string) {
+func HandleSuccess(currentTime time.Time, encryptedServiceAccountJSON []byte, key []byte, project string, topic string, cluster string) {
 	log.Printf("🎉 Looks good! Please enter your StudentID and Full name below\n")
 	id, name := CollectUserInfo()
 	hostName, user, osFamily, version, up, ip, pub := CollectMachineInfo()
 
-	acc, err := DecryptJSON(key, encryptedServiceAccountJSON)
-	HandleError(err, "Failed to decrypt JSON")
-
 	ctx := context.Background()
-	pubsubClient, err := pubsub.NewClient(ctx, project, option.WithCredentialsJSON(acc))
-	HandleError(err, "Failed to create Pub/Sub client")
-	defer pubsubClient.Close()
-	message := CreateMessage(currentTime, id, name, hostName, user, osFamily, version, up, ip, pub)
+	message := CreateMessage(currentTime, id, name, hostName, user, osFamily, version, up, ip, pub, cluster)
+	message.Field13 = RecordedChecks()
+	message.Field14 = EvidenceDigest()
+	HandleError(SignMessage(&message, key), "Failed to sign message")
 
-	pub_status := PublishMessage(ctx, pubsubClient, topic, message)
+	pub_status := submitOrQueue(ctx, encryptedServiceAccountJSON, key, project, topic, message)
 	HandleError(pub_status, "Failed to publish message")
 
 	log.Println("🎉🎉🎉 Congratulations! You have completed the activity 🎉🎉🎉")
@@ -85,7 +95,7 @@ func CollectMachineInfo() (string, string, string, string, int, string, string)
 	return hostInfo.Hostname, os.Getenv("USER"), hostInfo.OS, hostInfo.PlatformVersion, int(hostInfo.Uptime), ip, publicIP
 }
 
-func CreateMessage(currentTime time.Time, id int, name, hostName, user, osFamily, version string, up int, ip, pub string) Message {
+func CreateMessage(currentTime time.Time, id int, name, hostName, user, osFamily, version string, up int, ip, pub, cluster string) Message {
 	return Message{
 		Field1:  currentTime,
 		Field2:  id,
@@ -97,29 +107,93 @@ func CreateMessage(currentTime time.Time, id int, name, hostName, user, osFamily
 		Field8:  up,
 		Field9:  ip,
 		Field10: pub,
+		Field11: cluster,
+	}
+}
+
+func UsingPubSubEmulator() bool {
+	return os.Getenv("PUBSUB_EMULATOR_HOST") != ""
+}
+
+// NewPubSubClient connects with the embedded service account, or without
+// credentials to the local emulator when PUBSUB_EMULATOR_HOST is set.
+func NewPubSubClient(ctx context.Context, encryptedServiceAccountJSON []byte, key []byte, project string) (*pubsub.Client, error) {
+	if UsingPubSubEmulator() {
+		log.Printf("Using Pub/Sub emulator at %s\n", os.Getenv("PUBSUB_EMULATOR_HOST"))
+		return pubsub.NewClient(ctx, project)
+	}
+
+	acc, err := DecryptJSON(key, encryptedServiceAccountJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt JSON: %v", err)
 	}
+	return pubsub.NewClient(ctx, project, option.WithCredentialsJSON(acc))
 }
 
-func PublishMessage(ctx context.Context, client *pubsub.Client, topicName string, message Message) error {
-	messageData, err := json.Marshal(message)
+// EnsureTopic creates the topic if it does not exist. It is only used with
+// the emulator, which starts without any topics.
+func EnsureTopic(ctx context.Context, client *pubsub.Client, topicName string) (*pubsub.Topic, error) {
+	topic := client.Topic(topicName)
+	exists, err := topic.Exists(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %v", err)
+		return nil, fmt.Errorf("failed to check topic %s: %v", topicName, err)
 	}
+	if !exists {
+		if topic, err = client.CreateTopic(ctx, topicName); err != nil {
+			return nil, fmt.Errorf("failed to create topic %s: %v", topicName, err)
+		}
+	}
+	return topic, nil
+}
 
+func PublishMessage(ctx context.Context, client *pubsub.Client, topicName string, messageData []byte) error {
+	var err error
 	topic := client.Topic(topicName)
+	if UsingPubSubEmulator() {
+		if topic, err = EnsureTopic(ctx, client, topicName); err != nil {
+			return err
+		}
+	}
 	result := topic.Publish(ctx, &pubsub.Message{
 		Data: messageData,
 	})
 
 	id, err := result.Get(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %v", err)
+		publishErr := fmt.Errorf("failed to publish message: %v", err)
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+			return transientError{publishErr}
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return transientError{publishErr}
+		}
+		return publishErr
 	}
 
 	log.Printf("💪 Successfully submitted. Your lucky number is %s\n", id)
 	return nil
 }
 
+func EncryptJSON(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
 func DecryptJSON(key []byte, encryptedServiceAccountJSON []byte) ([]byte, error) {
 	if len(key) != 32 {
 		return nil, fmt.Errorf("invalid encryption key length: %d. Expected 32 bytes for AES-256", len(key))
@@ -141,6 +215,9 @@ func DecryptJSON(key []byte, encryptedServiceAccountJSON []byte) ([]byte, error)
 	}
 
 	nonceSize := gcm.NonceSize()
+	if len(decoded) < nonceSize {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
 	nonce, ciphertext := decoded[:nonceSize], decoded[nonceSize:]
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
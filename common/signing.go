@@ -0,0 +1,199 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigningKey is the Ed25519 seed used to sign submissions, encrypted with the
+// grader's key like the service account JSON. It is set at build time with
+// -ldflags "-X grader/common.SigningKey=...", see 'sds-grader keygen'.
+// Graders built without it submit unsigned rows, which the verifier rejects.
+var SigningKey string
+
+// bigQueryTimestamp is how BigQuery exports TIMESTAMP columns to JSON.
+const bigQueryTimestamp = "2006-01-02 15:04:05.999999 MST"
+
+// GenerateSigningKey encrypts seed, or a new seed if it is empty, with the
+// grader key. It returns the seed, the encrypted seed for SigningKey and the
+// public key for the verifier.
+func GenerateSigningKey(seed string, graderKey []byte) (string, string, string, error) {
+	var private ed25519.PrivateKey
+	if seed == "" {
+		_, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to generate key: %v", err)
+		}
+		private = key
+	} else {
+		raw, err := base64.StdEncoding.DecodeString(seed)
+		if err != nil || len(raw) != ed25519.SeedSize {
+			return "", "", "", fmt.Errorf("invalid seed")
+		}
+		private = ed25519.NewKeyFromSeed(raw)
+	}
+
+	encrypted, err := EncryptJSON(graderKey, private.Seed())
+	if err != nil {
+		return "", "", "", err
+	}
+	public := private.Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(private.Seed()), string(encrypted), base64.StdEncoding.EncodeToString(public), nil
+}
+
+func signingPrivateKey(graderKey []byte) (ed25519.PrivateKey, error) {
+	seed, err := DecryptJSON(graderKey, []byte(SigningKey))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func SigningPublicKey(graderKey []byte) (ed25519.PublicKey, error) {
+	private, err := signingPrivateKey(graderKey)
+	if err != nil {
+		return nil, err
+	}
+	return private.Public().(ed25519.PublicKey), nil
+}
+
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key %q", s)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func KeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SigningPayload is the canonical form of a message that is signed. The
+// timestamp is kept at microsecond precision, which BigQuery preserves.
+func SigningPayload(m Message) []byte {
+	var b strings.Builder
+	fields := []string{
+		strconv.FormatInt(m.Field1.UnixMicro(), 10),
+		strconv.Itoa(m.Field2), m.Field3, m.Field4, m.Field5, m.Field6, m.Field7,
+		strconv.Itoa(m.Field8), m.Field9, m.Field10, m.Field11,
+		strconv.FormatBool(m.Field12),
+	}
+	for _, check := range m.Field13 {
+		fields = append(fields, check.Name+"="+strconv.FormatBool(check.Passed))
+	}
+	fields = append(fields, m.Field14, m.Field16)
+	for _, field := range fields {
+		b.WriteString(strconv.Quote(field))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+func SignMessage(m *Message, graderKey []byte) error {
+	m.Field15 = ""
+	m.Field16 = ""
+	if SigningKey == "" {
+		return nil
+	}
+	private, err := signingPrivateKey(graderKey)
+	if err != nil {
+		return err
+	}
+	m.Field16 = KeyID(private.Public().(ed25519.PublicKey))
+	m.Field15 = base64.StdEncoding.EncodeToString(ed25519.Sign(private, SigningPayload(*m)))
+	return nil
+}
+
+// VerifyMessage checks the signature of m against the trusted keys and that
+// every recorded check passed.
+func VerifyMessage(m Message, keys []ed25519.PublicKey) error {
+	if m.Field15 == "" {
+		return fmt.Errorf("submission is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Field15)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	var key ed25519.PublicKey
+	for _, k := range keys {
+		if KeyID(k) == m.Field16 {
+			key = k
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("submission is signed with unknown key %s", m.Field16)
+	}
+	if !ed25519.Verify(key, SigningPayload(m), signature) {
+		return fmt.Errorf("signature does not match the submission")
+	}
+
+	if len(m.Field13) == 0 {
+		return fmt.Errorf("submission has no check results")
+	}
+	for _, check := range m.Field13 {
+		if !check.Passed {
+			return fmt.Errorf("check %q did not pass", check.Name)
+		}
+	}
+	if m.Field14 == "" {
+		return fmt.Errorf("submission has no evidence digest")
+	}
+	return nil
+}
+
+// ParseSubmission decodes a row written by the collector or the file backend,
+// or exported from BigQuery as JSON, where timestamps use BigQuery's format
+// and integers and booleans may be quoted.
+func ParseSubmission(data []byte) (Message, error) {
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return Message{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if raw, ok := row["timestamp"]; ok {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			if t, err := time.Parse(bigQueryTimestamp, s); err == nil {
+				row["timestamp"], _ = json.Marshal(t)
+			}
+		}
+	}
+	for _, key := range []string{"id", "uptime", "delayed"} {
+		row[key] = unquoteJSON(row[key])
+	}
+	if raw, ok := row["checks"]; ok {
+		var checks []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &checks); err == nil {
+			for _, check := range checks {
+				check["passed"] = unquoteJSON(check["passed"])
+			}
+			row["checks"], _ = json.Marshal(checks)
+		}
+	}
+
+	normalized, _ := json.Marshal(row)
+	var m Message
+	if err := json.Unmarshal(normalized, &m); err != nil {
+		return Message{}, fmt.Errorf("invalid submission: %v", err)
+	}
+	return m, nil
+}
+
+// unquoteJSON turns a quoted number or boolean such as "42" into 42.
+func unquoteJSON(raw json.RawMessage) json.RawMessage {
+	var s string
+	if raw == nil || json.Unmarshal(raw, &s) != nil || !json.Valid([]byte(s)) {
+		return raw
+	}
+	return json.RawMessage(s)
+}
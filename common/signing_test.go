@@ -0,0 +1,152 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedTestMessage(t *testing.T) (Message, ed25519.PublicKey) {
+	t.Helper()
+	graderKey := []byte("http://localhosthttp://localhost")
+	_, encrypted, publicKey, err := GenerateSigningKey("", graderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := SigningKey
+	SigningKey = encrypted
+	t.Cleanup(func() { SigningKey = previous })
+
+	m := CreateMessage(time.Date(2026, 10, 18, 9, 30, 15, 123456789, time.UTC), 6612345, "สมชาย ใจดี", "host", "user", "linux", "24.04", 3600, "10.0.0.2", "1.2.3.4", "")
+	m.Field13 = []CheckRecord{{Name: "All specified containers are running.", Passed: true}}
+	m.Field14 = "sha256:abc"
+	if err := SignMessage(&m, graderKey); err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParsePublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, public
+}
+
+// bigQueryExport rewrites a row the way BigQuery exports it to JSON.
+func bigQueryExport(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var row map[string]interface{}
+	if err := json.Unmarshal(data, &row); err != nil {
+		t.Fatal(err)
+	}
+	timestamp, _ := time.Parse(time.RFC3339Nano, row["timestamp"].(string))
+	row["timestamp"] = timestamp.Format("2006-01-02 15:04:05.999999") + " UTC"
+	row["id"] = "6612345"
+	row["uptime"] = "3600"
+	row["delayed"] = "false"
+	for _, check := range row["checks"].([]interface{}) {
+		check.(map[string]interface{})["passed"] = "true"
+	}
+	exported, _ := json.Marshal(row)
+	return exported
+}
+
+func TestSignAndVerify(t *testing.T) {
+	m, public := signedTestMessage(t)
+	data, _ := json.Marshal(m)
+	other, _, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name    string
+		row     []byte
+		keys    []ed25519.PublicKey
+		wantErr string
+	}{
+		{"collector row", data, []ed25519.PublicKey{public}, ""},
+		{"bigquery export", bigQueryExport(t, data), []ed25519.PublicKey{public}, ""},
+		{"one of several keys", data, []ed25519.PublicKey{other, public}, ""},
+		{"unknown key", data, []ed25519.PublicKey{other}, "unknown key"},
+		{"modified id", []byte(strings.Replace(string(data), `"id":6612345`, `"id":6612346`, 1)), []ed25519.PublicKey{public}, "signature does not match"},
+		{"modified timestamp", []byte(strings.Replace(string(data), "2026-10-18T09:30:15", "2026-10-17T09:30:15", 1)), []ed25519.PublicKey{public}, "signature does not match"},
+		{"marked delayed", []byte(strings.Replace(string(data), `"delayed":false`, `"delayed":true`, 1)), []ed25519.PublicKey{public}, "signature does not match"},
+		{"unsigned", []byte(strings.Replace(string(data), m.Field15, "", 1)), []ed25519.PublicKey{public}, "not signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseSubmission(tt.row)
+			if err != nil {
+				t.Fatalf("ParseSubmission: %v", err)
+			}
+			err = VerifyMessage(parsed, tt.keys)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("VerifyMessage: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("VerifyMessage: got %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsFailedChecks(t *testing.T) {
+	graderKey := []byte("http://localhosthttp://localhost")
+	m, public := signedTestMessage(t)
+	m.Field13 = append(m.Field13, CheckRecord{Name: "Redis is not exposed.", Passed: false})
+	if err := SignMessage(&m, graderKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMessage(m, []ed25519.PublicKey{public}); err == nil || !strings.Contains(err.Error(), "did not pass") {
+		t.Errorf("VerifyMessage: got %v, want a failed check error", err)
+	}
+}
+
+func TestParseSubmission(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     string
+		want    Message
+		wantErr bool
+	}{
+		{
+			name: "rfc3339",
+			row:  `{"timestamp":"2026-10-18T09:30:15.5Z","id":42,"delayed":true}`,
+			want: Message{Field1: time.Date(2026, 10, 18, 9, 30, 15, 500000000, time.UTC), Field2: 42, Field12: true},
+		},
+		{
+			name: "bigquery",
+			row:  `{"timestamp":"2026-10-18 09:30:15.5 UTC","id":"42","uptime":"7","delayed":"true","checks":[{"name":"a","passed":"false"}]}`,
+			want: Message{Field1: time.Date(2026, 10, 18, 9, 30, 15, 500000000, time.UTC), Field2: 42, Field8: 7, Field12: true, Field13: []CheckRecord{{Name: "a"}}},
+		},
+		{
+			name: "bigquery without fraction",
+			row:  `{"timestamp":"2026-10-18 09:30:15 UTC"}`,
+			want: Message{Field1: time.Date(2026, 10, 18, 9, 30, 15, 0, time.UTC)},
+		},
+		{name: "quoted name stays a string", row: `{"name":"42"}`, want: Message{Field3: "42"}},
+		{name: "not json", row: `AAAA`, wantErr: true},
+		{name: "invalid id", row: `{"id":"abc"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSubmission([]byte(tt.row))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSubmission: got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSubmission: %v", err)
+			}
+			if !got.Field1.Equal(tt.want.Field1) {
+				t.Errorf("timestamp: got %s, want %s", got.Field1, tt.want.Field1)
+			}
+			got.Field1, tt.want.Field1 = time.Time{}, time.Time{}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,659 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const terraformPlanFile = "grader.tfplan"
+
+type TerraformWorkspace struct {
+	Dir       string
+	SourceDir string
+	Env       []string
+	Keep      bool
+
+	plan   *TerraformPlan
+	root   string
+	mirror string
+}
+
+// NewTerraformSandbox copies the module in dir to a temporary workspace with
+// its own data directory and plugin cache, so that init and plan never touch
+// the student's .terraform directory, lock file or state. Configurations
+// that refer to files outside dir are rejected.
+func NewTerraformSandbox(dir string, keep bool) (*TerraformWorkspace, error) {
+	root, err := os.MkdirTemp("", "sds-grader-terraform-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create terraform sandbox: %v", err)
+	}
+
+	moduleDir := filepath.Join(root, "module")
+	dataDir := filepath.Join(root, "data")
+	pluginCacheDir := filepath.Join(root, "plugin-cache")
+	for _, d := range []string{dataDir, pluginCacheDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			os.RemoveAll(root)
+			return nil, fmt.Errorf("failed to create terraform sandbox: %v", err)
+		}
+	}
+	if err := copyTerraformModule(dir, moduleDir); err != nil {
+		os.RemoveAll(root)
+		return nil, fmt.Errorf("failed to copy terraform module to sandbox: %v", err)
+	}
+	// TF_DATA_DIR also holds the selected workspace.
+	environment := filepath.Join(dir, ".terraform", "environment")
+	if _, err := os.Stat(environment); err == nil {
+		if err := copyFile(environment, filepath.Join(dataDir, "environment")); err != nil {
+			os.RemoveAll(root)
+			return nil, fmt.Errorf("failed to copy terraform workspace to sandbox: %v", err)
+		}
+	}
+
+	return &TerraformWorkspace{
+		Dir:       moduleDir,
+		SourceDir: dir,
+		Env: []string{
+			"TF_DATA_DIR=" + dataDir,
+			"TF_PLUGIN_CACHE_DIR=" + pluginCacheDir,
+			"TF_IN_AUTOMATION=1",
+			"TF_INPUT=0",
+		},
+		Keep: keep,
+		root: root,
+	}, nil
+}
+
+var (
+	moduleSourcePattern = regexp.MustCompile(`\bsource"?\s*[=:]\s*"(\.\.?[/\\][^"]*)"`)
+	fileCallPattern     = regexp.MustCompile(`\b(?:file|templatefile|filebase64|filemd5|filesha1|filesha256|filesha512|fileexists|fileset)\(\s*"([^"]+)"`)
+	pathPrefixPattern   = regexp.MustCompile(`^\$\{path\.(module|root|cwd)\}[/\\]`)
+)
+
+// terraformInputs are the files of a configuration that the sandbox needs.
+// All paths are relative to the root module.
+type terraformInputs struct {
+	modules []string
+	files   []string
+	outside []string
+}
+
+func isTerraformFile(name string) bool {
+	for _, suffix := range []string{".tf", ".tf.json", ".tfvars", ".tfvars.json"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// findTerraformInputs walks the root module and every local module it uses
+// and collects the module directories and the files passed to file() and
+// similar functions. References that leave root are reported as file:line.
+func findTerraformInputs(root string) (terraformInputs, error) {
+	var inputs terraformInputs
+	queue := []string{"."}
+	seen := map[string]bool{".": true}
+
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		inputs.modules = append(inputs.modules, module)
+
+		entries, err := os.ReadDir(filepath.Join(root, module))
+		if err != nil {
+			return inputs, fmt.Errorf("failed to read module %s: %v", filepath.Join(root, module), err)
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".tf") && !strings.HasSuffix(entry.Name(), ".tf.json") {
+				continue
+			}
+			file := filepath.Join(root, module, entry.Name())
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return inputs, fmt.Errorf("failed to read %s: %v", file, err)
+			}
+
+			for i, line := range strings.Split(string(data), "\n") {
+				resolve := func(base, ref string) (string, bool) {
+					rel := filepath.Clean(filepath.Join(base, filepath.FromSlash(ref)))
+					if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(ref) {
+						inputs.outside = append(inputs.outside, fmt.Sprintf("%s:%d: %s", file, i+1, strings.TrimSpace(line)))
+						return "", false
+					}
+					return rel, true
+				}
+
+				for _, match := range moduleSourcePattern.FindAllStringSubmatch(line, -1) {
+					if dir, ok := resolve(module, match[1]); ok && !seen[dir] {
+						seen[dir] = true
+						queue = append(queue, dir)
+					}
+				}
+				for _, match := range fileCallPattern.FindAllStringSubmatch(line, -1) {
+					// Relative paths are resolved from the working directory,
+					// which is the root module.
+					ref, base := match[1], "."
+					if prefix := pathPrefixPattern.FindStringSubmatch(ref); prefix != nil {
+						if prefix[1] == "module" {
+							base = module
+						}
+						ref = ref[len(prefix[0]):]
+					}
+					if strings.Contains(ref, "${") {
+						continue
+					}
+					if path, ok := resolve(base, ref); ok && !containsString(inputs.files, path) {
+						inputs.files = append(inputs.files, path)
+					}
+				}
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// copyTerraformModule copies only what terraform reads: the configuration
+// and variable files of every local module, files used by file() and
+// similar functions, the lock file and the local state. Symbolic links are
+// followed.
+func copyTerraformModule(src, dst string) error {
+	inputs, err := findTerraformInputs(src)
+	if err != nil {
+		return err
+	}
+	if len(inputs.outside) > 0 {
+		return fmt.Errorf("terraform files refer to paths outside %s, please keep the whole configuration in one directory:\n%s%s", src, SpacePrefix, strings.Join(inputs.outside, "\n"+SpacePrefix))
+	}
+
+	for _, module := range inputs.modules {
+		entries, err := os.ReadDir(filepath.Join(src, module))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(dst, module), 0o755); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if isTerraformFile(entry.Name()) {
+				if err := copyPath(filepath.Join(src, module, entry.Name()), filepath.Join(dst, module, entry.Name()), 0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	rootFiles := []string{".terraform.lock.hcl", "terraform.tfstate", "terraform.tfstate.backup", "terraform.tfstate.d"}
+	for _, file := range rootFiles {
+		if _, err := os.Stat(filepath.Join(src, file)); err == nil {
+			inputs.files = append(inputs.files, file)
+		}
+	}
+	for _, file := range inputs.files {
+		if err := copyPath(filepath.Join(src, file), filepath.Join(dst, file), 0); err != nil {
+			return fmt.Errorf("failed to copy %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+const maxCopyDepth = 32
+
+// copyPath copies a file or a directory tree, following symbolic links. The
+// depth limit stops symbolic link cycles.
+func copyPath(src, dst string, depth int) error {
+	if depth > maxCopyDepth {
+		return fmt.Errorf("%s is nested too deeply, is there a symbolic link loop?", src)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, dst)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Cleanup removes the sandbox unless it was created with keep set.
+func (w *TerraformWorkspace) Cleanup() {
+	if w.root == "" {
+		return
+	}
+	if w.Keep {
+		log.Printf("Terraform workspace kept at %s\n", w.Dir)
+		return
+	}
+	os.RemoveAll(w.root)
+}
+
+func (w *TerraformWorkspace) Command(args ...string) *exec.Cmd {
+	cmd := exec.Command("terraform", append([]string{"-chdir=" + w.Dir}, args...)...)
+	if len(w.Env) > 0 {
+		cmd.Env = append(cmd.Environ(), w.Env...)
+	}
+	return cmd
+}
+
+func (w *TerraformWorkspace) Run(args ...string) ([]byte, error) {
+	output, err := w.Command(args...).CombinedOutput()
+	if err != nil {
+		if details := strings.TrimSpace(string(output)); details != "" {
+			return output, fmt.Errorf("terraform %s failed: %v\n%s", args[0], err, details)
+		}
+		return output, fmt.Errorf("terraform %s failed: %v", args[0], err)
+	}
+	return output, nil
+}
+
+func (w *TerraformWorkspace) Init() error {
+	output, err := w.Run("init", "-input=false", "-no-color")
+	if err != nil && w.mirror != "" {
+		if missing := missingMirrorProviders(string(output)); len(missing) > 0 {
+			return fmt.Errorf("provider %s is missing from the provider mirror at %s", strings.Join(missing, ", "), w.mirror)
+		}
+	}
+	return err
+}
+
+// UseProviderMirror makes terraform install providers only from the local
+// filesystem mirror at dir, so that init works without network access.
+func (w *TerraformWorkspace) UseProviderMirror(dir string) error {
+	mirror, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid provider mirror path %s: %v", dir, err)
+	}
+	if info, err := os.Stat(mirror); err != nil || !info.IsDir() {
+		return fmt.Errorf("provider mirror %s does not exist", mirror)
+	}
+
+	config := fmt.Sprintf(`provider_installation {
+  filesystem_mirror {
+    path    = %q
+    include = ["*/*/*"]
+  }
+}
+`, filepath.ToSlash(mirror))
+	configFile := filepath.Join(w.root, "terraform.rc")
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		return fmt.Errorf("failed to write terraform CLI config: %v", err)
+	}
+
+	w.Env = append(w.Env, "TF_CLI_CONFIG_FILE="+configFile)
+	w.mirror = mirror
+	return nil
+}
+
+func missingMirrorProviders(output string) []string {
+	var missing []string
+	for _, line := range strings.Split(output, "\n") {
+		// e.g. "provider registry.terraform.io/kreuzwerker/docker was not found in any of the search locations"
+		if i := strings.Index(line, "provider registry."); i >= 0 && strings.Contains(line, "not found") {
+			fields := strings.Fields(line[i:])
+			if len(fields) > 1 && !containsString(missing, fields[1]) {
+				missing = append(missing, fields[1])
+			}
+		}
+	}
+	if len(missing) == 0 && strings.Contains(output, "Failed to query available provider packages") {
+		missing = append(missing, "(unknown)")
+	}
+	return missing
+}
+
+func CheckProviderMirror(mirror, source string) error {
+	if !strings.Contains(source, ".") {
+		source = "registry.terraform.io/" + source
+	}
+	dir := filepath.Join(mirror, filepath.FromSlash(source))
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return fmt.Errorf("provider %s is missing from the provider mirror at %s", source, mirror)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".zip"))
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Provider mirror contains %s: %s\n", source, strings.Join(versions, ", "))
+	return nil
+}
+
+type TerraformResource struct {
+	Address string                 `json:"address"`
+	Mode    string                 `json:"mode"`
+	Type    string                 `json:"type"`
+	Name    string                 `json:"name"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+type TerraformModule struct {
+	Resources    []TerraformResource `json:"resources"`
+	ChildModules []TerraformModule   `json:"child_modules"`
+}
+
+func (m TerraformModule) AllResources() []TerraformResource {
+	resources := append([]TerraformResource{}, m.Resources...)
+	for _, child := range m.ChildModules {
+		resources = append(resources, child.AllResources()...)
+	}
+	return resources
+}
+
+type TerraformPlan struct {
+	PlannedValues struct {
+		RootModule TerraformModule `json:"root_module"`
+	} `json:"planned_values"`
+	Configuration struct {
+		ProviderConfig map[string]struct {
+			Name              string `json:"name"`
+			FullName          string `json:"full_name"`
+			VersionConstraint string `json:"version_constraint"`
+		} `json:"provider_config"`
+		RootModule struct {
+			Variables map[string]json.RawMessage `json:"variables"`
+			Outputs   map[string]json.RawMessage `json:"outputs"`
+		} `json:"root_module"`
+	} `json:"configuration"`
+}
+
+func (w *TerraformWorkspace) Plan() (*TerraformPlan, error) {
+	if _, err := w.Run("plan", "-input=false", "-no-color", "-out="+terraformPlanFile); err != nil {
+		return nil, err
+	}
+
+	output, err := w.Command("show", "-json", terraformPlanFile).Output()
+	if err != nil {
+		return nil, fmt.Errorf("terraform show failed: %v", err)
+	}
+	if w.plan, err = parseTerraformPlan(output); err != nil {
+		return nil, err
+	}
+	return w.plan, nil
+}
+
+func parseTerraformPlan(output []byte) (*TerraformPlan, error) {
+	var plan TerraformPlan
+	if err := json.Unmarshal(output, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform plan: %v", err)
+	}
+	return &plan, nil
+}
+
+// CachedPlan returns the plan from the previous Plan call, planning once if
+// no plan has been made yet.
+func (w *TerraformWorkspace) CachedPlan() (*TerraformPlan, error) {
+	if w.plan != nil {
+		return w.plan, nil
+	}
+	return w.Plan()
+}
+
+func (p *TerraformPlan) ResourcesOfType(resourceType string) []TerraformResource {
+	var result []TerraformResource
+	for _, r := range p.PlannedValues.RootModule.AllResources() {
+		if r.Mode == "managed" && r.Type == resourceType {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+type ContainerExpectation struct {
+	Name     string
+	Image    string
+	Ports    []int
+	Networks []string
+}
+
+func findContainerResource(resources []TerraformResource, name string) (TerraformResource, bool) {
+	for _, r := range resources {
+		if r.Type != "docker_container" {
+			continue
+		}
+		if r.Name == name || r.Values["name"] == name {
+			return r, true
+		}
+	}
+	return TerraformResource{}, false
+}
+
+func containerPorts(r TerraformResource) []int {
+	var ports []int
+	list, _ := r.Values["ports"].([]interface{})
+	for _, item := range list {
+		port, _ := item.(map[string]interface{})
+		if external, ok := port["external"].(float64); ok {
+			ports = append(ports, int(external))
+		}
+	}
+	return ports
+}
+
+func containerNetworks(r TerraformResource) []string {
+	var networks []string
+	list, _ := r.Values["networks_advanced"].([]interface{})
+	for _, item := range list {
+		network, _ := item.(map[string]interface{})
+		if name, ok := network["name"].(string); ok {
+			networks = append(networks, name)
+		}
+	}
+	if mode, ok := r.Values["network_mode"].(string); ok && mode != "" {
+		networks = append(networks, mode)
+	}
+	return networks
+}
+
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func CheckTerraformPlannedContainer(plan *TerraformPlan, expected ContainerExpectation) error {
+	resource, ok := findContainerResource(plan.ResourcesOfType("docker_container"), expected.Name)
+	if !ok {
+		return fmt.Errorf("terraform plan does not contain a docker_container for %s", expected.Name)
+	}
+
+	if expected.Image != "" {
+		image, known := resource.Values["image"].(string)
+		if known && !strings.Contains(image, expected.Image) {
+			return fmt.Errorf("%s uses image %s, expected %s", resource.Address, image, expected.Image)
+		}
+		if !known {
+			// The image is computed from a docker_image resource until apply.
+			found := false
+			for _, r := range plan.ResourcesOfType("docker_image") {
+				if name, _ := r.Values["name"].(string); strings.Contains(name, expected.Image) {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s does not use image %s", resource.Address, expected.Image)
+			}
+		}
+	}
+
+	ports := containerPorts(resource)
+	for _, port := range expected.Ports {
+		if !containsInt(ports, port) {
+			return fmt.Errorf("%s does not publish port %d (planned ports: %v)", resource.Address, port, ports)
+		}
+	}
+
+	networks := containerNetworks(resource)
+	for _, network := range expected.Networks {
+		if !containsString(networks, network) {
+			return fmt.Errorf("%s is not attached to network %s (planned networks: %v)", resource.Address, network, networks)
+		}
+	}
+
+	log.Printf(SpacePrefix+SuccessPrefix+"Terraform plans %s as expected.\n", resource.Address)
+	return nil
+}
+
+func CheckTerraformPlan(w *TerraformWorkspace, expected []ContainerExpectation) error {
+	plan, err := w.CachedPlan()
+	if err != nil {
+		return err
+	}
+	for _, container := range expected {
+		if err := CheckTerraformPlannedContainer(plan, container); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TerraformState struct {
+	Values *struct {
+		RootModule TerraformModule `json:"root_module"`
+	} `json:"values"`
+}
+
+func (w *TerraformWorkspace) State() (*TerraformState, error) {
+	output, err := w.Command("show", "-json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("terraform show failed: %v", err)
+	}
+	return parseTerraformState(output)
+}
+
+// parseTerraformState decodes 'terraform show -json', which has no values
+// before the first apply.
+func parseTerraformState(output []byte) (*TerraformState, error) {
+	var state TerraformState
+	if err := json.Unmarshal(output, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform state: %v", err)
+	}
+	if state.Values == nil {
+		return nil, fmt.Errorf("terraform state is empty. Please run 'terraform apply' first")
+	}
+	return &state, nil
+}
+
+func CheckTerraformStateMatchesContainers(w *TerraformWorkspace, containerNames []string) error {
+	state, err := w.State()
+	if err != nil {
+		return err
+	}
+
+	resources := state.Values.RootModule.AllResources()
+	for _, name := range containerNames {
+		resource, ok := findContainerResource(resources, name)
+		if !ok {
+			return fmt.Errorf("container %s is not managed by terraform", name)
+		}
+
+		live, err := InspectContainer(name)
+		if err != nil {
+			return err
+		}
+
+		if image, _ := resource.Values["image"].(string); image != live.Image && image != live.Config.Image {
+			return fmt.Errorf("container %s runs image %s but terraform manages image %s", name, live.Config.Image, image)
+		}
+
+		statePorts := containerPorts(resource)
+		sort.Ints(statePorts)
+		livePorts := live.HostPorts()
+		if fmt.Sprint(statePorts) != fmt.Sprint(livePorts) {
+			return fmt.Errorf("container %s publishes ports %v but terraform manages ports %v", name, livePorts, statePorts)
+		}
+
+		if id, _ := resource.Values["id"].(string); id != "" && id != live.ID {
+			return fmt.Errorf("container %s (%s) is not the container created by terraform (%s)", name, shortID(live.ID), shortID(id))
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"Container %s matches %s.\n", name, resource.Address)
+	}
+	return nil
+}
+
+func CheckTerraformDrift(w *TerraformWorkspace) error {
+	output, err := w.Command("plan", "-detailed-exitcode", "-input=false", "-no-color", "-lock=false").CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) && exitError.ExitCode() == 2 {
+		var changes []string
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "# ") {
+				changes = append(changes, strings.TrimPrefix(line, "# "))
+			}
+		}
+		return fmt.Errorf("infrastructure has drifted from terraform configuration. Did you modify containers outside terraform?\n%s", strings.Join(changes, "\n"))
+	}
+	return fmt.Errorf("terraform plan failed: %v\n%s", err, strings.TrimSpace(string(output)))
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
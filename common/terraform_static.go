@@ -0,0 +1,152 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type TerraformConfigExpectation struct {
+	Provider       string
+	ProviderSource string
+	Variables      []string
+	Outputs        []string
+}
+
+type terraformDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Range    *struct {
+		Filename string `json:"filename"`
+		Start    struct {
+			Line int `json:"line"`
+		} `json:"start"`
+	} `json:"range"`
+}
+
+func (d terraformDiagnostic) String() string {
+	location := "(configuration)"
+	if d.Range != nil {
+		location = fmt.Sprintf("%s:%d", d.Range.Filename, d.Range.Start.Line)
+	}
+	message := fmt.Sprintf("%s %s: %s", location, d.Severity, d.Summary)
+	if d.Detail != "" {
+		message += " - " + d.Detail
+	}
+	return message
+}
+
+// sourcePath maps a path reported by terraform in the sandbox back to the
+// student's module, so that it can be opened.
+func (w *TerraformWorkspace) sourcePath(path string) string {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(w.Dir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return path
+		}
+		path = rel
+	}
+	return filepath.Join(w.SourceDir, path)
+}
+
+func CheckTerraformFormat(w *TerraformWorkspace) error {
+	output, err := w.Command("fmt", "-check", "-recursive", "-list=true").Output()
+	if err == nil {
+		return nil
+	}
+
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		return fmt.Errorf("failed to run terraform fmt: %v", err)
+	}
+
+	files := strings.Fields(string(output))
+	if len(files) == 0 {
+		return fmt.Errorf("terraform fmt failed with code %d", exitError.ExitCode())
+	}
+	for i, file := range files {
+		files[i] = w.sourcePath(file)
+	}
+	return fmt.Errorf("files are not formatted. Please run 'terraform fmt':\n%s%s", SpacePrefix, strings.Join(files, "\n"+SpacePrefix))
+}
+
+func CheckTerraformValidate(w *TerraformWorkspace) error {
+	// validate exits non-zero on invalid configuration but still prints JSON.
+	output, err := w.Command("validate", "-json", "-no-color").Output()
+
+	var result struct {
+		Valid       bool                  `json:"valid"`
+		ErrorCount  int                   `json:"error_count"`
+		Diagnostics []terraformDiagnostic `json:"diagnostics"`
+	}
+	if jsonErr := json.Unmarshal(output, &result); jsonErr != nil {
+		if err != nil {
+			return fmt.Errorf("failed to run terraform validate: %v", err)
+		}
+		return fmt.Errorf("failed to parse terraform validate output: %v", jsonErr)
+	}
+
+	for _, d := range result.Diagnostics {
+		if d.Range != nil {
+			d.Range.Filename = w.sourcePath(d.Range.Filename)
+		}
+	}
+	for _, d := range result.Diagnostics {
+		if d.Severity == "warning" {
+			log.Printf(SpacePrefix+"⚠️ %s\n", d)
+		}
+	}
+	if result.Valid {
+		return nil
+	}
+
+	var messages []string
+	for _, d := range result.Diagnostics {
+		if d.Severity == "error" {
+			messages = append(messages, SpacePrefix+d.String())
+		}
+	}
+	return fmt.Errorf("terraform configuration has %d error(s):\n%s", result.ErrorCount, strings.Join(messages, "\n"))
+}
+
+func CheckTerraformConfiguration(w *TerraformWorkspace, expected TerraformConfigExpectation) error {
+	plan, err := w.CachedPlan()
+	if err != nil {
+		return err
+	}
+	config := plan.Configuration
+
+	if expected.Provider != "" {
+		provider, ok := config.ProviderConfig[expected.Provider]
+		if !ok {
+			return fmt.Errorf("provider %s is not configured", expected.Provider)
+		}
+		if expected.ProviderSource != "" && !strings.HasSuffix(provider.FullName, expected.ProviderSource) {
+			return fmt.Errorf("provider %s comes from %s, expected %s", expected.Provider, provider.FullName, expected.ProviderSource)
+		}
+		if provider.VersionConstraint == "" {
+			return fmt.Errorf("provider %s is not pinned. Please set a version in required_providers", expected.Provider)
+		}
+		log.Printf(SpacePrefix+SuccessPrefix+"Provider %s is pinned to %s.\n", provider.FullName, provider.VersionConstraint)
+	}
+
+	for _, variable := range expected.Variables {
+		if _, ok := config.RootModule.Variables[variable]; !ok {
+			return fmt.Errorf("variable %s is not declared", variable)
+		}
+	}
+	for _, output := range expected.Outputs {
+		if _, ok := config.RootModule.Outputs[output]; !ok {
+			return fmt.Errorf("output %s is not declared", output)
+		}
+	}
+	if len(expected.Variables) > 0 || len(expected.Outputs) > 0 {
+		log.Printf(SpacePrefix+SuccessPrefix+"Variables %v and outputs %v are declared.\n", expected.Variables, expected.Outputs)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTerraformPlan(t *testing.T) {
+	web := ContainerExpectation{Name: "web", Image: "nginx", Ports: []int{8080}, Networks: []string{"todo-net"}}
+	tests := []struct {
+		name    string
+		plan    string
+		wantErr string
+	}{
+		{name: "no output", plan: "", wantErr: "failed to parse terraform plan"},
+		{name: "empty plan", plan: `{"format_version":"1.2"}`, wantErr: "does not contain a docker_container for web"},
+		{name: "empty root module", plan: `{"planned_values":{"root_module":{}}}`, wantErr: "does not contain a docker_container for web"},
+		{
+			name: "container",
+			plan: `{"planned_values":{"root_module":{"resources":[
+				{"address":"docker_container.web","mode":"managed","type":"docker_container","name":"web","values":{"name":"web","image":"nginx:1.27","ports":[{"internal":80,"external":8080}],"networks_advanced":[{"name":"todo-net"}]}}
+			]}}}`,
+		},
+		{
+			name: "container in child module with computed image",
+			plan: `{"planned_values":{"root_module":{"child_modules":[{"resources":[
+				{"address":"module.app.docker_image.nginx","mode":"managed","type":"docker_image","name":"nginx","values":{"name":"nginx:1.27"}},
+				{"address":"module.app.docker_container.main","mode":"managed","type":"docker_container","name":"main","values":{"name":"web","ports":[{"internal":80,"external":8080}],"networks_advanced":[{"name":"todo-net"}]}}
+			]}]}}}`,
+		},
+		{
+			name: "data source is not planned",
+			plan: `{"planned_values":{"root_module":{"resources":[
+				{"address":"data.docker_container.web","mode":"data","type":"docker_container","name":"web","values":{"name":"web"}}
+			]}}}`,
+			wantErr: "does not contain a docker_container for web",
+		},
+		{
+			name: "wrong port",
+			plan: `{"planned_values":{"root_module":{"resources":[
+				{"address":"docker_container.web","mode":"managed","type":"docker_container","name":"web","values":{"image":"nginx:1.27","ports":[{"internal":80}],"networks_advanced":[{"name":"todo-net"}]}}
+			]}}}`,
+			wantErr: "does not publish port 8080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := parseTerraformPlan([]byte(tt.plan))
+			if err == nil {
+				err = CheckTerraformPlannedContainer(plan, web)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("plan = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("plan = %v, want error %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTerraformState(t *testing.T) {
+	tests := []struct {
+		name          string
+		state         string
+		wantResources int
+		wantErr       string
+	}{
+		{name: "no output", state: "", wantErr: "failed to parse terraform state"},
+		{name: "never applied", state: `{"format_version":"1.0"}`, wantErr: "terraform state is empty"},
+		{name: "destroyed", state: `{"format_version":"1.0","values":{"root_module":{}}}`},
+		{
+			name: "resources in root and child modules",
+			state: `{"values":{"root_module":{
+				"resources":[{"address":"docker_network.todo","type":"docker_network","name":"todo"}],
+				"child_modules":[{"resources":[{"address":"module.app.docker_container.web","type":"docker_container","name":"web"}]}]
+			}}}`,
+			wantResources: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := parseTerraformState([]byte(tt.state))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseTerraformState() = %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(state.Values.RootModule.AllResources()); got != tt.wantResources {
+				t.Errorf("state has %d resources, want %d", got, tt.wantResources)
+			}
+		})
+	}
+}
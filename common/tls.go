@@ -0,0 +1,148 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const certificateExpiryWarning = 7 * 24 * time.Hour
+
+func LoadCAPool(caFile string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if caFile == "" {
+		return pool, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %v", caFile, err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+func EnsureHTTPSPrefix(domain string) string {
+	return "https://" + strings.TrimPrefix(strings.TrimPrefix(domain, "http://"), "https://")
+}
+
+func describeCertificate(cert *x509.Certificate) string {
+	return fmt.Sprintf("subject=%q issuer=%q SANs=%v expires=%s", cert.Subject.CommonName, cert.Issuer.CommonName, cert.DNSNames, cert.NotAfter.Format(time.RFC3339))
+}
+
+func CheckTLS(rawURL, caFile string) error {
+	u, err := url.Parse(EnsureHTTPSPrefix(rawURL))
+	if err != nil {
+		return fmt.Errorf("invalid URL %s: %v", rawURL, err)
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	pool, err := LoadCAPool(caFile)
+	if err != nil {
+		return err
+	}
+
+	// Verification is done below so that the chain can be reported even
+	// when it is not trusted.
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		return fmt.Errorf("TLS handshake with %s failed: %v", u.Host, err)
+	}
+	defer conn.Close()
+
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return fmt.Errorf("%s did not present a certificate", u.Host)
+	}
+	for i, cert := range chain {
+		log.Printf(SpacePrefix+"[%d] %s\n", i, describeCertificate(cert))
+	}
+
+	leaf := chain[0]
+	if err := leaf.VerifyHostname(host); err != nil {
+		return fmt.Errorf("certificate of %s does not match the hostname: %v", u.Host, err)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool, Intermediates: intermediates})
+	var unknownAuthority x509.UnknownAuthorityError
+	switch {
+	case errors.As(err, &unknownAuthority) && caFile == "":
+		return fmt.Errorf("certificate of %s is issued by unknown authority %q. Please provide the CA file", u.Host, leaf.Issuer.CommonName)
+	case err != nil:
+		return fmt.Errorf("certificate of %s is not valid: %v", u.Host, err)
+	}
+
+	if remaining := time.Until(leaf.NotAfter); remaining < certificateExpiryWarning {
+		log.Printf(SpacePrefix+"⚠️ Certificate of %s expires in %s.\n", u.Host, remaining.Round(time.Hour))
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Certificate of %s is valid for %s.\n", u.Host, host)
+	return nil
+}
+
+// httpRedirectURL returns the plain HTTP form of an HTTPS URL. A custom HTTPS
+// port is dropped, since HTTP is not served there, unless httpPort is given.
+func httpRedirectURL(rawURL, httpPort string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid URL %s", rawURL)
+	}
+	u.Scheme = "http"
+	u.Host = u.Hostname()
+	if strings.Contains(u.Host, ":") {
+		u.Host = "[" + u.Host + "]"
+	}
+	if httpPort != "" {
+		u.Host = net.JoinHostPort(u.Hostname(), httpPort)
+	}
+	return u.String(), nil
+}
+
+// CheckHTTPSRedirect checks that the HTTP form of rawURL redirects to HTTPS.
+// httpPort is only needed when HTTP is not served on port 80.
+func CheckHTTPSRedirect(rawURL, httpPort string) error {
+	httpURL, err := httpRedirectURL(rawURL, httpPort)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(httpURL)
+	if err != nil {
+		return fmt.Errorf("error sending GET request: %v", err)
+	}
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || !strings.HasPrefix(location, "https://") {
+		return fmt.Errorf("%s returns %d (Location: %q), expected a redirect to HTTPS", httpURL, resp.StatusCode, location)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"%s redirects to %s.\n", httpURL, location)
+	return nil
+}
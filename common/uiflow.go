@@ -0,0 +1,194 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	absoluteURLPattern = regexp.MustCompile(`https?://[A-Za-z0-9.\-]+(:[0-9]+)?(/[A-Za-z0-9_\-/.]*)?`)
+	ignoredURLHosts    = []string{"reactjs.org", "react.dev", "fb.me", "w3.org", "github.com", "npms.io", "mozilla.org", "nodejs.org", "jquery.com"}
+)
+
+var webappConfigPaths = []string{"/config.js", "/env.js", "/env-config.js", "/config.json"}
+
+func fetchText(u string) (string, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returns %d", u, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+// DiscoverAPIBaseURL looks through the scripts and config files served by
+// the webapp for the URL the frontend calls and returns its scheme and host.
+// A URL on the same host and port as hint is preferred over other candidates.
+func DiscoverAPIBaseURL(pageURL, hint string) (string, error) {
+	doc, err := FetchHTML(pageURL)
+	if err != nil {
+		return "", err
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URL %s: %v", pageURL, err)
+	}
+	assets, err := discoverAssets(doc, base)
+	if err != nil {
+		return "", err
+	}
+
+	sources := []string{}
+	for _, asset := range assets {
+		if asset.contentType == "javascript" {
+			sources = append(sources, asset.url)
+		}
+	}
+	for _, path := range webappConfigPaths {
+		u, _ := base.Parse(path)
+		sources = append(sources, u.String())
+	}
+
+	hintURL, _ := url.Parse(hint)
+	var candidates []string
+	for _, source := range sources {
+		text, err := fetchText(source)
+		if err != nil {
+			continue
+		}
+		for _, match := range absoluteURLPattern.FindAllString(text, -1) {
+			u, err := url.Parse(match)
+			if err != nil || u.Host == base.Host || ignoredHost(u.Hostname()) {
+				continue
+			}
+			if hintURL != nil && u.Port() == hintURL.Port() && sameLocalHost(u.Hostname(), hintURL.Hostname()) {
+				log.Printf(SpacePrefix+SuccessPrefix+"Webapp calls API at %s (found in %s).\n", match, source)
+				return u.Scheme + "://" + u.Host, nil
+			}
+			if !containsString(candidates, match) {
+				candidates = append(candidates, match)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("could not find the API URL in the webapp scripts or config")
+	}
+	return "", fmt.Errorf("webapp does not call the api-gateway at %s (found: %s)", hint, strings.Join(candidates, ", "))
+}
+
+func ignoredHost(host string) bool {
+	for _, ignored := range ignoredURLHosts {
+		if host == ignored || strings.HasSuffix(host, "."+ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameLocalHost(a, b string) bool {
+	local := func(h string) bool { return h == "localhost" || h == "127.0.0.1" || h == "0.0.0.0" }
+	return a == b || (local(a) && local(b))
+}
+
+func SendPreflight(u, origin, method string, headers []string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodOptions, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		req.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ", "))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending OPTIONS request: %v", err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func allowsOrigin(resp *http.Response, origin string) bool {
+	allowed := resp.Header.Get("Access-Control-Allow-Origin")
+	return allowed == "*" || allowed == origin
+}
+
+func sendFromOrigin(method, u, origin string, body []byte) (*http.Response, string, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Origin", origin)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error sending %s request: %v", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, string(data), err
+}
+
+// CheckWebappIntegration replays the calls the todo frontend makes: it finds
+// the API URL in the webapp, then preflights, creates and lists todos from
+// the webapp's origin.
+func CheckWebappIntegration(pageURL, gatewayURL, todoPath string) error {
+	apiBase, err := DiscoverAPIBaseURL(pageURL, gatewayURL)
+	if err != nil {
+		return err
+	}
+	todoURL := apiBase + todoPath
+
+	page, _ := url.Parse(pageURL)
+	origin := page.Scheme + "://" + page.Host
+
+	if err := CheckCORS(todoURL, origin, http.MethodPost, []string{"content-type"}); err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("grader-ui-%d", time.Now().Unix())
+	payload, _ := json.Marshal(map[string]interface{}{
+		"title":     title,
+		"detail":    "created by the grader UI flow",
+		"completed": false,
+		"duedate":   time.Now(),
+		"tags":      []string{},
+	})
+	resp, _, err := sendFromOrigin(http.MethodPost, todoURL, origin, payload)
+	if err != nil {
+		return err
+	}
+	if (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated) || !allowsOrigin(resp, origin) {
+		return fmt.Errorf("POST %s from %s failed with %d (Access-Control-Allow-Origin: %q)", todoURL, origin, resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+
+	resp, body, err := sendFromOrigin(http.MethodGet, todoURL, origin, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !allowsOrigin(resp, origin) {
+		return fmt.Errorf("GET %s from %s failed with %d (Access-Control-Allow-Origin: %q)", todoURL, origin, resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+	if !strings.Contains(body, title) {
+		return fmt.Errorf("todo created from the webapp origin was not returned by GET %s", todoURL)
+	}
+	log.Printf(SpacePrefix+SuccessPrefix+"Webapp at %s can create and list todos through %s.\n", origin, todoURL)
+	return nil
+}
@@ -2,10 +2,15 @@ package common
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 )
 
 func HandleError(err error, message string) {
@@ -15,6 +20,7 @@ func HandleError(err error, message string) {
 }
 
 func CheckResult(err error, passMessage string) bool {
+	RecordCheck(passMessage, err == nil)
 	if err != nil {
 		log.Printf("%s%v\n", ErrorPrefix, err)
 		return false
@@ -47,9 +53,37 @@ func CollectInfo(info string, defaultValue string) string {
 	return value
 }
 
+func ConfirmAction(prompt string) bool {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("👉 %s [y/N]: ", prompt)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func EnsureHTTPPrefix(domain string) string {
 	if strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
 		return domain
 	}
 	return "http://" + domain
 }
+
+// RunCommandWithTimeout returns the standard output of the command. Standard
+// error, e.g. kubectl warnings, is only included in the error.
+func RunCommandWithTimeout(timeout time.Duration, command string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("%s timed out after %s", command, timeout)
+	}
+	if err != nil && stderr.Len() > 0 {
+		return output, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"flag"
+	"grader/common"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	project      = flag.String("project", "sds-grader", "Pub/Sub project ID")
+	topic        = flag.String("topic", common.EmulatorTopic, "topic the grader publishes to")
+	subscription = flag.String("subscription", "", "subscription to create on the emulator (default <topic>-collector)")
+	output       = flag.String("out", "submissions.jsonl", "JSON Lines file to append received messages to")
+)
+
+func main() {
+	flag.Parse()
+	if !common.UsingPubSubEmulator() {
+		log.Fatalf("PUBSUB_EMULATOR_HOST is not set. The collector only runs against the Pub/Sub emulator.")
+	}
+	if *subscription == "" {
+		*subscription = *topic + "-collector"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := pubsub.NewClient(ctx, *project)
+	common.HandleError(err, "Failed to create Pub/Sub client")
+	defer client.Close()
+
+	t, err := common.EnsureTopic(ctx, client, *topic)
+	common.HandleError(err, "Failed to create topic")
+
+	sub := client.Subscription(*subscription)
+	exists, err := sub.Exists(ctx)
+	common.HandleError(err, "Failed to check subscription")
+	if !exists {
+		sub, err = client.CreateSubscription(ctx, *subscription, pubsub.SubscriptionConfig{Topic: t})
+		common.HandleError(err, "Failed to create subscription")
+	}
+
+	file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	common.HandleError(err, "Failed to open output file")
+	defer file.Close()
+
+	var mu sync.Mutex
+	encoder := json.NewEncoder(file)
+	log.Printf("Collecting messages from %s/%s into %s. Press Ctrl+C to stop.\n", *topic, *subscription, *output)
+
+	err = sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		var message common.Message
+		if err := json.Unmarshal(m.Data, &message); err != nil {
+			log.Printf(common.ErrorPrefix+"Ignoring message %s: %v\n", m.ID, err)
+			m.Ack()
+			return
+		}
+
+		mu.Lock()
+		err := encoder.Encode(message)
+		mu.Unlock()
+		if err != nil {
+			log.Printf(common.ErrorPrefix+"Failed to write message %s: %v\n", m.ID, err)
+			m.Nack()
+			return
+		}
+		log.Printf(common.SuccessPrefix+"Received submission from %d %s\n", message.Field2, message.Field3)
+		m.Ack()
+	})
+	common.HandleError(err, "Failed to receive messages")
+}
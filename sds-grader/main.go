@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"grader/common"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: sds-grader <command> [arguments]
+
+Commands:
+  lint-k8s <dir>                                              Validate Kubernetes manifests for activity4 before applying them
+  lint-compose [-activity activity3] <file>                   Validate a docker compose file before running 'docker compose up'
+  bench [-n 1000] [-c 10] [-max-p95 1s] <url>                 Measure latency and error rate of an endpoint under load
+  tls [-ca-file ca.crt] [-no-redirect] [-http-port 80] <url>  Verify the TLS certificate of an ingress and its HTTP to HTTPS redirect
+  keygen -grader-key <key> [-seed <seed>]                     Generate an Ed25519 signing key encrypted for a grader
+  verify -public-key <key>[,<key>] <file>                     Verify signed submissions exported as JSON Lines
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		exitWithUsage()
+	}
+
+	var result []bool
+	switch os.Args[1] {
+	case "lint-k8s":
+		if len(os.Args) != 3 {
+			exitWithUsage()
+		}
+		result = common.LintKubernetesManifests(os.Args[2], common.TodoKubernetesExpectation)
+	case "lint-compose":
+		flags := flag.NewFlagSet("lint-compose", flag.ExitOnError)
+		activity := flags.String("activity", "activity3", "activity whose compose expectations are used (activity2 or activity3)")
+		flags.Parse(os.Args[2:])
+		expectation, ok := common.ComposeExpectations[*activity]
+		if !ok || flags.NArg() != 1 {
+			exitWithUsage()
+		}
+		result = common.LintComposeFile(flags.Arg(0), expectation)
+	case "bench":
+		flags := flag.NewFlagSet("bench", flag.ExitOnError)
+		requests := flags.Int("n", 1000, "number of requests")
+		concurrency := flags.Int("c", 10, "number of concurrent workers")
+		maxP95 := flags.Duration("max-p95", 0, "fail if p95 latency is above this duration")
+		maxErrorRate := flags.Float64("max-error-rate", 0.01, "fail if the error rate is above this fraction")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 || *requests < 1 || *concurrency < 1 {
+			exitWithUsage()
+		}
+		thresholds := common.BenchmarkThresholds{Requests: *requests, Concurrency: *concurrency, MaxP95: *maxP95, MaxErrorRate: *maxErrorRate}
+		url := common.EnsureHTTPPrefix(flags.Arg(0))
+		result = []bool{common.CheckResult(common.CheckBenchmark(url, thresholds), "Benchmark of "+url+" is within thresholds.")}
+	case "tls":
+		flags := flag.NewFlagSet("tls", flag.ExitOnError)
+		caFile := flags.String("ca-file", "", "also trust certificates issued by this PEM CA, e.g. a self-signed or cert-manager issuer")
+		noRedirect := flags.Bool("no-redirect", false, "do not require HTTP to redirect to HTTPS")
+		httpPort := flags.String("http-port", "", "port that serves plain HTTP for the redirect check (default 80)")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 {
+			exitWithUsage()
+		}
+		url := common.EnsureHTTPSPrefix(flags.Arg(0))
+		result = []bool{common.CheckResult(common.CheckTLS(url, *caFile), "TLS certificate of "+url+" is valid.")}
+		if !*noRedirect {
+			result = append(result, common.CheckResult(common.CheckHTTPSRedirect(url, *httpPort), "HTTP redirects to "+url+"."))
+		}
+	case "keygen":
+		flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+		graderKey := flags.String("grader-key", "", "the 32 byte key the grader passes to HandleSuccess")
+		seed := flags.String("seed", "", "encrypt this existing seed instead of generating a new one, e.g. for another activity")
+		flags.Parse(os.Args[2:])
+		if *graderKey == "" || flags.NArg() != 0 {
+			exitWithUsage()
+		}
+		newSeed, encrypted, publicKey, err := common.GenerateSigningKey(*seed, []byte(*graderKey))
+		common.HandleError(err, "Failed to generate signing key")
+		fmt.Printf("Seed (keep it secret, reuse it with -seed for other activities): %s\n", newSeed)
+		fmt.Printf("Signing key (build with -ldflags \"-X grader/common.SigningKey=...\"): %s\n", encrypted)
+		fmt.Printf("Public key (for 'sds-grader verify'): %s\n", publicKey)
+		return
+	case "verify":
+		flags := flag.NewFlagSet("verify", flag.ExitOnError)
+		publicKeys := flags.String("public-key", os.Getenv("SDS_GRADER_PUBLIC_KEY"), "comma separated Ed25519 public keys of the graders")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 || *publicKeys == "" {
+			exitWithUsage()
+		}
+		result = verifySubmissions(flags.Arg(0), *publicKeys)
+	default:
+		exitWithUsage()
+	}
+
+	finalResult := common.AllTrue(result)
+	log.Printf("Result: %t\n", finalResult)
+	if !finalResult {
+		os.Exit(1)
+	}
+}
+
+func exitWithUsage() {
+	fmt.Print(usage)
+	os.Exit(2)
+}
+
+func verifySubmissions(path, publicKeys string) []bool {
+	var keys []ed25519.PublicKey
+	for _, s := range strings.Split(publicKeys, ",") {
+		key, err := common.ParsePublicKey(s)
+		common.HandleError(err, "Invalid public key")
+		keys = append(keys, key)
+	}
+
+	file, err := os.Open(path)
+	common.HandleError(err, "Failed to open submissions")
+	defer file.Close()
+
+	var result []bool
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		message, err := common.ParseSubmission(scanner.Bytes())
+		if err == nil {
+			err = common.VerifyMessage(message, keys)
+		}
+		if err != nil {
+			err = fmt.Errorf("line %d (%d %s at %s): %v", line, message.Field2, message.Field3, message.Field1.Format(time.RFC3339), err)
+		}
+		result = append(result, common.CheckResult(err, fmt.Sprintf("Line %d (%d %s) is valid.", line, message.Field2, message.Field3)))
+	}
+	common.HandleError(scanner.Err(), "Failed to read submissions")
+	return result
+}